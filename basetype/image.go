@@ -19,6 +19,16 @@ package basetype
 import (
 	"fmt"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"regexp"
+)
+
+var (
+	// the reference grammar of github.com/distribution/reference
+	repositoryRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9]+(?:[.-][a-zA-Z0-9]+)*(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagRegexp             = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	supportedPullPolicies = []string{string(v1.PullAlways), string(v1.PullIfNotPresent), string(v1.PullNever)}
 )
 
 // +k8s:openapi-gen=true
@@ -60,3 +70,20 @@ func (in *Image) SetDefaults(repository, tag string, pullPolicy v1.PullPolicy) (
 	}
 	return
 }
+
+// Validate validates the image; the empty fields are allowed as they are set by SetDefaults
+func (in *Image) Validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.Repository != "" && !repositoryRegexp.MatchString(in.Repository) {
+		errs = append(errs, field.Invalid(path.Child("repository"), in.Repository,
+			"must be a valid image repository e.g. docker.io/library/busybox"))
+	}
+	if in.Tag != "" && !tagRegexp.MatchString(in.Tag) {
+		errs = append(errs, field.Invalid(path.Child("tag"), in.Tag,
+			"must be a valid image tag of at most 128 letters, digits, '_', '.' and '-'"))
+	}
+	if in.PullPolicy != "" && !oneOf(string(in.PullPolicy), supportedPullPolicies) {
+		errs = append(errs, field.NotSupported(path.Child("pullPolicy"), in.PullPolicy, supportedPullPolicies))
+	}
+	return errs
+}
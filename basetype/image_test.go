@@ -0,0 +1,79 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package basetype
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"testing"
+)
+
+// errorFields describes the errors by type and field
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Type.String()+" "+err.Field)
+	}
+	return fields
+}
+
+func TestImageValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		image Image
+		want  []string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			image: Image{
+				Repository: "registry.example.com:5000/team/app",
+				Tag:        "1.2.3-alpine",
+				PullPolicy: v1.PullIfNotPresent,
+			},
+		},
+		{
+			name:  "bad repository",
+			image: Image{Repository: "Docker.io/App"},
+			want:  []string{"Invalid value image.repository"},
+		},
+		{
+			name:  "repository with a tag",
+			image: Image{Repository: "busybox:latest"},
+			want:  []string{"Invalid value image.repository"},
+		},
+		{
+			name:  "bad tag",
+			image: Image{Repository: "busybox", Tag: ".latest"},
+			want:  []string{"Invalid value image.tag"},
+		},
+		{
+			name:  "bad pull policy",
+			image: Image{Repository: "busybox", PullPolicy: "Sometimes"},
+			want:  []string{"Unsupported value image.pullPolicy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorFields(tt.image.Validate(field.NewPath("image")))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -17,8 +17,21 @@
 package basetype
 
 import (
+	"fmt"
 	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	supportedRestartPolicies    = []string{string(v1.RestartPolicyAlways), string(v1.RestartPolicyOnFailure), string(v1.RestartPolicyNever)}
+	supportedDNSPolicies        = []string{string(v1.DNSClusterFirstWithHostNet), string(v1.DNSClusterFirst), string(v1.DNSDefault), string(v1.DNSNone)}
+	supportedPreemptionPolicies = []string{string(v1.PreemptLowerPriority), string(v1.PreemptNever)}
+	supportedTolerationOps      = []string{string(v1.TolerationOpEqual), string(v1.TolerationOpExists)}
+	supportedTaintEffects       = []string{string(v1.TaintEffectNoSchedule), string(v1.TaintEffectPreferNoSchedule), string(v1.TaintEffectNoExecute)}
 )
 
 // +k8s:openapi-gen=true
@@ -101,3 +114,124 @@ type PodSpec struct {
 
 	PreemptionPolicy *v1.PreemptionPolicy `json:"preemptionPolicy,omitempty" protobuf:"bytes,31,opt,name=preemptionPolicy"`
 }
+
+// Validate validates the labels and annotations of the pod metadata and the pod spec
+func (in *PodConfig) Validate(path *field.Path) field.ErrorList {
+	metadataPath := path.Child("metadata")
+	errs := metav1validation.ValidateLabels(in.Labels, metadataPath.Child("labels"))
+	errs = append(errs, apivalidation.ValidateAnnotations(in.Annotations, metadataPath.Child("annotations"))...)
+	return append(errs, in.Spec.Validate(path.Child("spec"))...)
+}
+
+// Validate validates the pod spec with the rules the API server applies to the pods created from it
+func (in *PodSpec) Validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, env := range in.Env {
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			errs = append(errs, field.Invalid(path.Child("env").Index(i).Child("name"), env.Name, msg))
+		}
+	}
+	if in.ActiveDeadlineSeconds != nil && *in.ActiveDeadlineSeconds <= 0 {
+		errs = append(errs, field.Invalid(path.Child("activeDeadlineSeconds"), *in.ActiveDeadlineSeconds,
+			"must be greater than 0"))
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		errs = append(errs, apivalidation.ValidateNonnegativeField(*in.TerminationGracePeriodSeconds,
+			path.Child("terminationGracePeriodSeconds"))...)
+	}
+	errs = append(errs, validateOneOf(path.Child("restartPolicy"), string(in.RestartPolicy), supportedRestartPolicies)...)
+	errs = append(errs, validateOneOf(path.Child("dnsPolicy"), string(in.DNSPolicy), supportedDNSPolicies)...)
+	if in.PreemptionPolicy != nil {
+		errs = append(errs, validateOneOf(path.Child("preemptionPolicy"), string(*in.PreemptionPolicy), supportedPreemptionPolicies)...)
+	}
+	errs = append(errs, validateDNSSubdomain(path.Child("serviceAccountName"), in.ServiceAccountName)...)
+	errs = append(errs, validateDNSSubdomain(path.Child("nodeName"), in.NodeName)...)
+	errs = append(errs, validateDNSSubdomain(path.Child("priorityClassName"), in.PriorityClassName)...)
+	for i := range in.Tolerations {
+		errs = append(errs, validateToleration(path.Child("tolerations").Index(i), &in.Tolerations[i])...)
+	}
+	errs = append(errs, metav1validation.ValidateLabels(in.Labels, path.Child("labels"))...)
+	errs = append(errs, apivalidation.ValidateAnnotations(in.Annotations, path.Child("annotations"))...)
+	errs = append(errs, metav1validation.ValidateLabels(in.NodeSelector, path.Child("nodeSelector"))...)
+	errs = append(errs, validateResources(path.Child("resources"), &in.Resources)...)
+	errs = append(errs, validateResourceList(path.Child("overhead"), in.Overhead)...)
+	return errs
+}
+
+// validateToleration applies the toleration rules of the API server
+func validateToleration(path *field.Path, toleration *v1.Toleration) field.ErrorList {
+	var errs field.ErrorList
+	if toleration.Key != "" {
+		errs = append(errs, metav1validation.ValidateLabelName(toleration.Key, path.Child("key"))...)
+	} else if toleration.Operator != v1.TolerationOpExists {
+		errs = append(errs, field.Invalid(path.Child("operator"), toleration.Operator,
+			"operator must be Exists when `key` is empty, which means \"match all values and all keys\""))
+	}
+	if toleration.TolerationSeconds != nil && toleration.Effect != v1.TaintEffectNoExecute {
+		errs = append(errs, field.Invalid(path.Child("effect"), toleration.Effect,
+			"effect must be 'NoExecute' when `tolerationSeconds` is set"))
+	}
+	errs = append(errs, validateOneOf(path.Child("operator"), string(toleration.Operator), supportedTolerationOps)...)
+	if toleration.Operator == v1.TolerationOpExists && toleration.Value != "" {
+		errs = append(errs, field.Invalid(path.Child("value"), toleration.Value,
+			"value must be empty when `operator` is 'Exists'"))
+	}
+	if toleration.Operator != v1.TolerationOpExists {
+		for _, msg := range validation.IsValidLabelValue(toleration.Value) {
+			errs = append(errs, field.Invalid(path.Child("value"), toleration.Value, msg))
+		}
+	}
+	return append(errs, validateOneOf(path.Child("effect"), string(toleration.Effect), supportedTaintEffects)...)
+}
+
+// validateResources checks the quantities are not negative and the requests do not exceed the limits
+func validateResources(path *field.Path, resources *v1.ResourceRequirements) field.ErrorList {
+	errs := validateResourceList(path.Child("limits"), resources.Limits)
+	errs = append(errs, validateResourceList(path.Child("requests"), resources.Requests)...)
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(path.Child("requests").Key(string(name)), request.String(),
+				fmt.Sprintf("must be less than or equal to %s limit of %s", name, limit.String())))
+		}
+	}
+	return errs
+}
+
+func validateResourceList(path *field.Path, resources v1.ResourceList) field.ErrorList {
+	var errs field.ErrorList
+	for name, quantity := range resources {
+		if quantity.Sign() < 0 {
+			errs = append(errs, field.Invalid(path.Key(string(name)), quantity.String(),
+				"must be greater than or equal to 0"))
+		}
+	}
+	return errs
+}
+
+func validateDNSSubdomain(path *field.Path, value string) field.ErrorList {
+	var errs field.ErrorList
+	if value == "" {
+		return errs
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(value) {
+		errs = append(errs, field.Invalid(path, value, msg))
+	}
+	return errs
+}
+
+// validateOneOf checks the value is empty, and defaulted later, or one of the supported values
+func validateOneOf(path *field.Path, value string, supported []string) field.ErrorList {
+	if value == "" || oneOf(value, supported) {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(path, value, supported)}
+}
+
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package basetype
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"testing"
+)
+
+func TestPodConfigValidate(t *testing.T) {
+	gracePeriod := int64(-1)
+	deadline := int64(0)
+	tolerationSeconds := int64(30)
+	tests := []struct {
+		name string
+		pod  PodConfig
+		want []string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			pod: PodConfig{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec: PodSpec{
+					Env:           []v1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+					RestartPolicy: v1.RestartPolicyAlways,
+					Tolerations: []v1.Toleration{
+						{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "web", Effect: v1.TaintEffectNoSchedule},
+						{Operator: v1.TolerationOpExists},
+					},
+				},
+			},
+		},
+		{
+			name: "bad metadata label",
+			pod:  PodConfig{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "-web"}}},
+			want: []string{"Invalid value pod.metadata.labels"},
+		},
+		{
+			name: "negative grace period",
+			pod:  PodConfig{Spec: PodSpec{TerminationGracePeriodSeconds: &gracePeriod}},
+			want: []string{"Invalid value pod.spec.terminationGracePeriodSeconds"},
+		},
+		{
+			name: "zero active deadline",
+			pod:  PodConfig{Spec: PodSpec{ActiveDeadlineSeconds: &deadline}},
+			want: []string{"Invalid value pod.spec.activeDeadlineSeconds"},
+		},
+		{
+			name: "bad env name",
+			pod:  PodConfig{Spec: PodSpec{Env: []v1.EnvVar{{Name: "1=A"}}}},
+			want: []string{"Invalid value pod.spec.env[0].name"},
+		},
+		{
+			name: "bad restart policy",
+			pod:  PodConfig{Spec: PodSpec{RestartPolicy: "Sometimes"}},
+			want: []string{"Unsupported value pod.spec.restartPolicy"},
+		},
+		{
+			name: "bad toleration operator",
+			pod: PodConfig{Spec: PodSpec{Tolerations: []v1.Toleration{
+				{Key: "dedicated", Operator: "In", Value: "web"},
+			}}},
+			want: []string{"Unsupported value pod.spec.tolerations[0].operator"},
+		},
+		{
+			name: "toleration without key nor Exists operator",
+			pod: PodConfig{Spec: PodSpec{Tolerations: []v1.Toleration{
+				{Operator: v1.TolerationOpEqual, Value: "web"},
+			}}},
+			want: []string{"Invalid value pod.spec.tolerations[0].operator"},
+		},
+		{
+			name: "toleration seconds without NoExecute",
+			pod: PodConfig{Spec: PodSpec{Tolerations: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule, TolerationSeconds: &tolerationSeconds},
+			}}},
+			want: []string{"Invalid value pod.spec.tolerations[0].effect"},
+		},
+		{
+			name: "toleration value with Exists",
+			pod: PodConfig{Spec: PodSpec{Tolerations: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpExists, Value: "web"},
+			}}},
+			want: []string{"Invalid value pod.spec.tolerations[0].value"},
+		},
+		{
+			name: "requests over the limits",
+			pod: PodConfig{Spec: PodSpec{Resources: v1.ResourceRequirements{
+				Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			}}},
+			want: []string{"Invalid value pod.spec.resources.requests[cpu]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorFields(tt.pod.Validate(field.NewPath("pod")))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
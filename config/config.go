@@ -38,8 +38,12 @@ import (
 	"sync"
 )
 
+// DefaultOperatorName is the operator name of the defaults derived from it before the logger is created
+const DefaultOperatorName = "operator-helper"
+
 var logger logr.Logger
 var loggerOnce sync.Once
+var operatorName string
 
 var envOperatorHost = "K8S-OPERATOR_HOST"
 var envEnableWebHooks = "ENABLE_WEBHOOKS"
@@ -49,6 +53,11 @@ var envNamespacesToWatch = "NAMESPACES_TO_WATCH"
 var envEnableLeaderElection = "ENABLE_LEADER_ELECTION"
 var envLeaderElectionNamespace = "LEADER_ELECTION_NAMESPACE"
 var envMetricsServerPort = "METRICS_SERVER_PORT"
+var envDryRun = "DRY_RUN"
+var envWebHookCertProvisioning = "WEBHOOK_CERTIFICATES_PROVISIONING"
+var envWebHookServiceName = "WEBHOOK_SERVICE_NAME"
+var envWebHookServiceNamespace = "WEBHOOK_SERVICE_NAMESPACE"
+var envWebHookCertificateSecret = "WEBHOOK_CERTIFICATES_SECRET"
 
 // RequireRootLogger get the root logger or panic if not yet created
 func RequireRootLogger() logr.Logger {
@@ -58,16 +67,30 @@ func RequireRootLogger() logr.Logger {
 	return logger
 }
 
-// GetLogger get the logger instance to use
-func GetLogger(operatorName string, opts ...zap.Opts) logr.Logger {
+// GetLogger get the logger instance to use; it is configured through the LOG_* env vars
+func GetLogger(name string, opts ...zap.Opts) logr.Logger {
 	loggerOnce.Do(func() {
-		opts = append([]zap.Opts{zap.UseDevMode(true)}, opts...)
-		logger = zap.New(opts...).WithName(operatorName)
+		operatorName = name
+		opts = append(loggingOpts(), opts...)
+		logger = zap.New(opts...).WithName(name)
 		ctrl.SetLogger(logger)
 	})
 	return logger
 }
 
+// OperatorName returns the operator name the logger was created with or empty if not yet created
+func OperatorName() string {
+	return operatorName
+}
+
+// operatorNameOrDefault returns the operator name or DefaultOperatorName if the logger is not yet created
+func operatorNameOrDefault() string {
+	if operatorName != "" {
+		return operatorName
+	}
+	return DefaultOperatorName
+}
+
 // NewRestConfig creates new rest config or panic
 func NewRestConfig() *rest.Config {
 	return config.GetConfigOrDie()
@@ -130,10 +153,17 @@ func metricServerAddress() string {
 	return fmt.Sprintf(":%d", port)
 }
 
+// DryRunEnabled checks if the reconcilers should only plan their changes, sending every write
+// to the API server as a dry-run
+func DryRunEnabled() bool {
+	return strings.TrimSpace(os.Getenv(envDryRun)) == "true"
+}
+
 // WebHooksEnabled checks if webhook is enabled
 func WebHooksEnabled() bool {
 	if strings.TrimSpace(os.Getenv(envEnableWebHooks)) != "false" {
-		if _, err := os.Stat(GetWebHookCertDir()); !os.IsNotExist(err) {
+		if _, err := os.Stat(GetWebHookCertDir()); !os.IsNotExist(err) || WebHookCertProvisioningEnabled() {
+			// the provisioned certificates are written when the webhooks are set up
 			return true
 		}
 		log.Printf("The webhook cert directory does not exists: %s", GetWebHookCertDir())
@@ -169,3 +199,24 @@ func GetWebHookCertDir() string {
 	def := filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
 	return oputil.ValueOr(envWebHookCertificateDir, def)
 }
+
+// WebHookCertProvisioningEnabled checks if the operator should provision its own self-signed webhook
+// certificates instead of expecting them in the cert directory, e.g. from cert-manager
+func WebHookCertProvisioningEnabled() bool {
+	return strings.TrimSpace(os.Getenv(envWebHookCertProvisioning)) == "true"
+}
+
+// WebHookServiceName get the name of the Service of the webhook server
+func WebHookServiceName() string {
+	return oputil.ValueOr(envWebHookServiceName, fmt.Sprintf("%s-webhook-service", operatorNameOrDefault()))
+}
+
+// WebHookServiceNamespace get the namespace of the Service of the webhook server
+func WebHookServiceNamespace() string {
+	return oputil.ValueOr(envWebHookServiceNamespace, LeaderElectionNamespace(operatorNameOrDefault()))
+}
+
+// WebHookCertSecretName get the name of the Secret storing the provisioned webhook certificates
+func WebHookCertSecretName() string {
+	return oputil.ValueOr(envWebHookCertificateSecret, fmt.Sprintf("%s-cert", WebHookServiceName()))
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWebHookDefaultsBeforeLogger(t *testing.T) {
+	for _, env := range []string{envWebHookServiceName, envWebHookServiceNamespace, envWebHookCertificateSecret, envLeaderElectionNamespace} {
+		if os.Getenv(env) != "" {
+			t.Skipf("%s is set", env)
+		}
+	}
+	if OperatorName() != "" {
+		t.Skip("the logger is created")
+	}
+	if got, want := WebHookServiceName(), DefaultOperatorName+"-webhook-service"; got != want {
+		t.Errorf("expected the service name %q, got: %q", want, got)
+	}
+	if got := WebHookServiceNamespace(); got == "" {
+		t.Errorf("expected a service namespace")
+	}
+	if got, want := WebHookCertSecretName(), DefaultOperatorName+"-webhook-service-cert"; got != want {
+		t.Errorf("expected the secret name %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"go.uber.org/zap/zapcore"
+	"log"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"strconv"
+	"strings"
+)
+
+var envLogDevelopment = "LOG_DEVELOPMENT"
+var envLogFormat = "LOG_FORMAT"
+var envLogLevel = "LOG_LEVEL"
+var envLogStacktraceLevel = "LOG_STACKTRACE_LEVEL"
+var envLogTimeEncoding = "LOG_TIME_ENCODING"
+
+// loggingOpts get the logger options set through the env. LOG_DEVELOPMENT=true uses the zap development
+// defaults, LOG_FORMAT is json or console, LOG_LEVEL and LOG_STACKTRACE_LEVEL are debug, info, error or a
+// logr verbosity e.g. 3 and LOG_TIME_ENCODING is epoch, millis, nano, iso8601, rfc3339 or rfc3339nano.
+// The defaults are json, info level, stacktraces on errors and rfc3339 time
+func loggingOpts() []zap.Opts {
+	var opts []zap.Opts
+	if strings.TrimSpace(os.Getenv(envLogDevelopment)) == "true" {
+		opts = append(opts, zap.UseDevMode(true))
+	}
+	switch format := strings.TrimSpace(os.Getenv(envLogFormat)); format {
+	case "":
+	case "json":
+		opts = append(opts, func(o *zap.Options) {
+			o.NewEncoder = encoderOf(zap.JSONEncoder)
+		})
+	case "console":
+		opts = append(opts, func(o *zap.Options) {
+			o.NewEncoder = encoderOf(zap.ConsoleEncoder)
+		})
+	default:
+		log.Fatalf("Invalid %s=%s", envLogFormat, format)
+	}
+	if level, ok := logLevel(envLogLevel); ok {
+		opts = append(opts, zap.Level(level))
+	}
+	if level, ok := logLevel(envLogStacktraceLevel); ok {
+		opts = append(opts, zap.StacktraceLevel(level))
+	}
+	if encoding := strings.TrimSpace(os.Getenv(envLogTimeEncoding)); encoding != "" {
+		var encoder zapcore.TimeEncoder
+		if err := encoder.UnmarshalText([]byte(encoding)); err != nil {
+			log.Fatalf("Invalid %s=%s", envLogTimeEncoding, encoding)
+		}
+		opts = append(opts, func(o *zap.Options) {
+			o.TimeEncoder = encoder
+		})
+	}
+	return opts
+}
+
+// encoderOf adapts the zap encoder option to a NewEncoderFunc so the time encoding still applies
+func encoderOf(encoder func(opts ...zap.EncoderConfigOption) func(o *zap.Options)) zap.NewEncoderFunc {
+	return func(opts ...zap.EncoderConfigOption) zapcore.Encoder {
+		o := &zap.Options{}
+		encoder(opts...)(o)
+		return o.Encoder
+	}
+}
+
+// logLevel parses the level set in the env as a zap level name or a logr verbosity
+func logLevel(env string) (zapcore.Level, bool) {
+	value := strings.TrimSpace(os.Getenv(env))
+	if value == "" {
+		return 0, false
+	}
+	if verbosity, err := strconv.Atoi(value); err == nil && verbosity >= 0 {
+		// logr verbosity is the negated zap level
+		return zapcore.Level(-verbosity), true
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		log.Fatalf("Invalid %s=%s", env, value)
+	}
+	return level, true
+}
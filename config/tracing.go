@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"os"
+	"strings"
+)
+
+// The standard OpenTelemetry env vars; the otlp exporter reads the others e.g. OTEL_EXPORTER_OTLP_HEADERS
+var envTracesExporter = "OTEL_TRACES_EXPORTER"
+var envOtlpEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+var envOtlpTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+
+// TracesExporter get the traces exporter: otlp, console or none. It defaults to
+// otlp when an OTLP endpoint is set, otherwise none
+func TracesExporter() string {
+	if exporter := strings.TrimSpace(os.Getenv(envTracesExporter)); exporter != "" {
+		return exporter
+	}
+	if os.Getenv(envOtlpEndpoint) != "" || os.Getenv(envOtlpTracesEndpoint) != "" {
+		return "otlp"
+	}
+	return "none"
+}
+
+// TracingEnabled checks if a traces exporter is configured
+func TracingEnabled() bool {
+	return TracesExporter() != "none"
+}
+
+// SetupTracing sets the global tracer provider exporting to the configured exporter and returns its
+// shutdown function, which flushes the pending spans. Nothing is set up when tracing is not enabled
+func SetupTracing(ctx context.Context, serviceName string) (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+	switch TracesExporter() {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unsupported %s=%s", envTracesExporter, TracesExporter())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("traces exporter create error: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drift
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Report describes how a live object drifted from its desired state
+type Report struct {
+	// Paths are the field paths whose live value differs from the desired one e.g. spec.replicas
+	Paths []string
+	// Diff describes the drifted fields, one per line
+	Diff string
+}
+
+// NeedsUpdate checks if the live object drifted and needs to be updated
+func (r Report) NeedsUpdate() bool {
+	return len(r.Paths) > 0
+}
+
+// Detect compares the desired object, e.g. built by statefulset.New or service.New, to the live
+// object. Only the fields set in the desired object are compared, so the fields defaulted by the
+// server or set by other controllers are ignored. The status and the metadata other than the
+// labels and annotations are ignored too. A zero value is set only through a pointer e.g. replicas: 0;
+// the zero values of the other fields, e.g. the targetPort of a service port, are taken as unset
+func Detect(desired, live runtime.Object) (Report, error) {
+	desiredMap, err := toComparable(desired)
+	if err != nil {
+		return Report{}, err
+	}
+	pruneZeros(reflect.ValueOf(desired), desiredMap)
+	liveMap, err := toComparable(live)
+	if err != nil {
+		return Report{}, err
+	}
+	d := &detector{diffs: map[string]string{}}
+	d.compare("", desiredMap, liveMap)
+	return d.report(), nil
+}
+
+// Compare reports every field that differs between the two versions of an object, e.g. the live object
+// and the one returned by a dry-run write. Unlike Detect the status and the fields set only in the live
+// object are compared too; the metadata maintained by the server is ignored
+func Compare(desired, live runtime.Object) (Report, error) {
+	desiredMap, err := toFullComparable(desired)
+	if err != nil {
+		return Report{}, err
+	}
+	liveMap, err := toFullComparable(live)
+	if err != nil {
+		return Report{}, err
+	}
+	d := &detector{diffs: map[string]string{}, full: true}
+	d.compare("", desiredMap, liveMap)
+	return d.report(), nil
+}
+
+func toFullComparable(obj runtime.Object) (map[string]interface{}, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(m, "apiVersion")
+	delete(m, "kind")
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		for _, key := range []string{"resourceVersion", "managedFields", "generation", "uid", "creationTimestamp", "selfLink"} {
+			delete(metadata, key)
+		}
+	}
+	return m, nil
+}
+
+func toComparable(obj runtime.Object) (map[string]interface{}, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(m, "apiVersion")
+	delete(m, "kind")
+	delete(m, "status")
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		m["metadata"] = map[string]interface{}{
+			"labels":      metadata["labels"],
+			"annotations": metadata["annotations"],
+		}
+	}
+	return m, nil
+}
+
+// pruneZeros removes from the unstructured form of the typed value the zero values of its non-pointer
+// fields. They are what the unset fields convert to and the server may default them to other values
+func pruneZeros(v reflect.Value, u interface{}) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			pruneZeros(v.Elem(), u)
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := u.([]interface{}); ok {
+			for i := 0; i < v.Len() && i < len(list); i++ {
+				pruneZeros(v.Index(i), list[i])
+			}
+		}
+	case reflect.Map:
+		if m, ok := u.(map[string]interface{}); ok && v.Type().Key().Kind() == reflect.String {
+			iter := v.MapRange()
+			for iter.Next() {
+				pruneZeros(iter.Value(), m[iter.Key().String()])
+			}
+		}
+	case reflect.Struct:
+		if m, ok := u.(map[string]interface{}); ok {
+			pruneStructZeros(v, m)
+		}
+	}
+}
+
+func pruneStructZeros(v reflect.Value, m map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if name == "" && (field.Anonymous || (len(tag) > 1 && tag[1] == "inline")) {
+			pruneZeros(fv, m)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, ok := m[name]
+		if !ok {
+			continue
+		}
+		if fv.Kind() != reflect.Ptr && fv.IsZero() {
+			if _, isMap := value.(map[string]interface{}); !isMap {
+				// a zero scalar or a zero struct converted to one e.g. an IntOrString
+				delete(m, name)
+				continue
+			}
+		}
+		pruneZeros(fv, value)
+	}
+}
+
+type detector struct {
+	diffs map[string]string
+	// full compares the fields set only in the live object too
+	full bool
+}
+
+func (d *detector) report() Report {
+	report := Report{}
+	for path := range d.diffs {
+		report.Paths = append(report.Paths, path)
+	}
+	sort.Strings(report.Paths)
+	lines := make([]string, 0, len(report.Paths))
+	for _, path := range report.Paths {
+		lines = append(lines, d.diffs[path])
+	}
+	report.Diff = strings.Join(lines, "\n")
+	return report
+}
+
+func (d *detector) compare(path string, desired, live interface{}) {
+	if isUnset(desired) {
+		if d.full && !isUnset(live) {
+			d.drifted(path, desired, live)
+		}
+		return
+	}
+	switch dv := desired.(type) {
+	case map[string]interface{}:
+		lv, ok := live.(map[string]interface{})
+		if !ok {
+			d.drifted(path, desired, live)
+			return
+		}
+		for key, value := range dv {
+			d.compare(join(path, key), value, lv[key])
+		}
+		if d.full {
+			for key, value := range lv {
+				if _, ok = dv[key]; !ok {
+					d.compare(join(path, key), nil, value)
+				}
+			}
+		}
+	case []interface{}:
+		lv, ok := live.([]interface{})
+		if !ok || len(dv) != len(lv) {
+			d.drifted(path, desired, live)
+			return
+		}
+		for i := range dv {
+			d.compare(fmt.Sprintf("%s[%d]", path, i), dv[i], lv[i])
+		}
+	default:
+		if live == nil && isZero(desired) && !d.full {
+			// a zero value the server does not keep e.g. of an unstructured desired object
+			return
+		}
+		if !scalarEqual(desired, live) {
+			d.drifted(path, desired, live)
+		}
+	}
+}
+
+func (d *detector) drifted(path string, desired, live interface{}) {
+	d.diffs[path] = fmt.Sprintf("%s: live=%v desired=%v", path, live, desired)
+}
+
+// isUnset checks if the desired value is empty and therefore not compared
+func isUnset(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+func scalarEqual(desired, live interface{}) bool {
+	if equality.Semantic.DeepEqual(desired, live) {
+		return true
+	}
+	ds, ok1 := desired.(string)
+	ls, ok2 := live.(string)
+	if ok1 && ok2 {
+		// the server canonicalizes the quantities e.g. 1000m to 1
+		dq, err1 := resource.ParseQuantity(ds)
+		lq, err2 := resource.ParseQuantity(ls)
+		return err1 == nil && err2 == nil && dq.Cmp(lq) == 0
+	}
+	return false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drift_test
+
+import (
+	"github.com/monimesl/operator-helper/k8s/drift"
+	"github.com/monimesl/operator-helper/k8s/service"
+	"github.com/monimesl/operator-helper/k8s/statefulset"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"reflect"
+	"testing"
+)
+
+func desiredService() *v1.Service {
+	return service.New2("test", "test-svc", true, map[string]string{"app": "test"},
+		[]v1.ServicePort{{Name: "http", Port: 80}})
+}
+
+// serverDefaultedService returns the Service as the API server stores the desired one
+func serverDefaultedService() *v1.Service {
+	svc := desiredService()
+	svc.UID = "8f2c1b4e"
+	svc.ResourceVersion = "42"
+	svc.Spec.Type = v1.ServiceTypeClusterIP
+	svc.Spec.ClusterIP = "10.96.12.7"
+	svc.Spec.ClusterIPs = []string{"10.96.12.7"}
+	svc.Spec.SessionAffinity = v1.ServiceAffinityNone
+	singleStack := v1.IPFamilyPolicySingleStack
+	svc.Spec.IPFamilyPolicy = &singleStack
+	svc.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol}
+	internal := v1.ServiceInternalTrafficPolicyCluster
+	svc.Spec.InternalTrafficPolicy = &internal
+	svc.Spec.Ports[0].Protocol = v1.ProtocolTCP
+	svc.Spec.Ports[0].TargetPort = intstr.FromInt(80)
+	return svc
+}
+
+func desiredStatefulSet() *appsv1.StatefulSet {
+	labels := map[string]string{"app": "test"}
+	template := v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:  "test",
+				Image: "example.com/test:1.0",
+				Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				ReadinessProbe: &v1.Probe{
+					ProbeHandler: v1.ProbeHandler{
+						HTTPGet: &v1.HTTPGetAction{Path: "/ready", Port: intstr.FromString("http")},
+					},
+				},
+			}},
+		},
+	}
+	pvc := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	spec := statefulset.NewSpec(3, "test-svc", labels, []v1.PersistentVolumeClaim{pvc}, template)
+	return statefulset.New("test", "test", labels, spec)
+}
+
+// serverDefaultedStatefulSet returns the StatefulSet as the API server stores the desired one
+func serverDefaultedStatefulSet() *appsv1.StatefulSet {
+	sts := desiredStatefulSet()
+	sts.UID = "0d6b7a51"
+	sts.ResourceVersion = "42"
+	sts.Generation = 1
+	revisionHistoryLimit := int32(10)
+	sts.Spec.RevisionHistoryLimit = &revisionHistoryLimit
+	partition := int32(0)
+	sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	sts.Spec.PersistentVolumeClaimRetentionPolicy = &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+	}
+	filesystem := v1.PersistentVolumeFilesystem
+	sts.Spec.VolumeClaimTemplates[0].Spec.VolumeMode = &filesystem
+	sts.Spec.VolumeClaimTemplates[0].Status.Phase = v1.ClaimPending
+	podSpec := &sts.Spec.Template.Spec
+	podSpec.RestartPolicy = v1.RestartPolicyAlways
+	podSpec.DNSPolicy = v1.DNSClusterFirst
+	podSpec.SchedulerName = v1.DefaultSchedulerName
+	podSpec.SecurityContext = &v1.PodSecurityContext{}
+	gracePeriod := int64(30)
+	podSpec.TerminationGracePeriodSeconds = &gracePeriod
+	container := &podSpec.Containers[0]
+	container.ImagePullPolicy = v1.PullIfNotPresent
+	container.TerminationMessagePath = v1.TerminationMessagePathDefault
+	container.TerminationMessagePolicy = v1.TerminationMessageReadFile
+	container.Ports[0].Protocol = v1.ProtocolTCP
+	probe := container.ReadinessProbe
+	probe.HTTPGet.Scheme = v1.URISchemeHTTP
+	probe.TimeoutSeconds = 1
+	probe.PeriodSeconds = 10
+	probe.SuccessThreshold = 1
+	probe.FailureThreshold = 3
+	sts.Status.Replicas = 3
+	sts.Status.ObservedGeneration = 1
+	return sts
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired runtime.Object
+		live    runtime.Object
+		paths   []string
+	}{
+		{
+			name:    "server defaulted service",
+			desired: desiredService(),
+			live:    serverDefaultedService(),
+		},
+		{
+			name:    "server defaulted statefulset",
+			desired: desiredStatefulSet(),
+			live:    serverDefaultedStatefulSet(),
+		},
+		{
+			name:    "service port edited",
+			desired: desiredService(),
+			live: func() runtime.Object {
+				svc := serverDefaultedService()
+				svc.Spec.Ports[0].Port = 8080
+				return svc
+			}(),
+			paths: []string{"spec.ports[0].port"},
+		},
+		{
+			name: "service target port set",
+			desired: func() runtime.Object {
+				svc := desiredService()
+				svc.Spec.Ports[0].TargetPort = intstr.FromInt(8080)
+				return svc
+			}(),
+			live:  serverDefaultedService(),
+			paths: []string{"spec.ports[0].targetPort"},
+		},
+		{
+			name:    "service label removed",
+			desired: desiredService(),
+			live: func() runtime.Object {
+				svc := serverDefaultedService()
+				svc.Labels = map[string]string{}
+				return svc
+			}(),
+			paths: []string{"metadata.labels"},
+		},
+		{
+			name:    "statefulset scaled",
+			desired: desiredStatefulSet(),
+			live: func() runtime.Object {
+				sts := serverDefaultedStatefulSet()
+				replicas := int32(5)
+				sts.Spec.Replicas = &replicas
+				return sts
+			}(),
+			paths: []string{"spec.replicas"},
+		},
+		{
+			name: "statefulset scaled to zero",
+			desired: func() runtime.Object {
+				sts := desiredStatefulSet()
+				replicas := int32(0)
+				sts.Spec.Replicas = &replicas
+				return sts
+			}(),
+			live:  serverDefaultedStatefulSet(),
+			paths: []string{"spec.replicas"},
+		},
+		{
+			name:    "statefulset image edited",
+			desired: desiredStatefulSet(),
+			live: func() runtime.Object {
+				sts := serverDefaultedStatefulSet()
+				sts.Spec.Template.Spec.Containers[0].Image = "example.com/test:2.0"
+				return sts
+			}(),
+			paths: []string{"spec.template.spec.containers[0].image"},
+		},
+		{
+			name:    "statefulset storage canonicalized",
+			desired: desiredStatefulSet(),
+			live: func() runtime.Object {
+				sts := serverDefaultedStatefulSet()
+				sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("1024Mi")
+				return sts
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := drift.Detect(tt.desired, tt.live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Paths, tt.paths) {
+				t.Errorf("expected the drifted paths %v, got: %v\n%s", tt.paths, report.Paths, report.Diff)
+			}
+			if report.NeedsUpdate() != (len(tt.paths) > 0) {
+				t.Errorf("expected NeedsUpdate: %v", len(tt.paths) > 0)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	live := serverDefaultedService()
+	updated := serverDefaultedService()
+	updated.ResourceVersion = "43"
+	report, err := drift.Compare(updated, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NeedsUpdate() {
+		t.Errorf("expected no change apart from the server metadata, got: %s", report.Diff)
+	}
+	// the fields set only in one version are compared too
+	updated.Spec.SessionAffinity = ""
+	if report, err = drift.Compare(updated, live); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"spec.sessionAffinity"}; !reflect.DeepEqual(report.Paths, want) {
+		t.Errorf("expected the changed paths %v, got: %v", want, report.Paths)
+	}
+}
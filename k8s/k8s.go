@@ -18,6 +18,8 @@ package k8s
 
 import (
 	"context"
+	"github.com/monimesl/operator-helper/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	k8Labels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -55,13 +57,22 @@ func ContainerShellCommand() []string {
 
 // WaitForPodsToTerminate wait for all the pods matching the labels to terminate
 func WaitForPodsToTerminate(k8sClient client.Client, namespace string, labels map[string]string) (err error) {
+	return WaitForPodsToTerminateWithContext(context.TODO(), k8sClient, namespace, labels)
+}
+
+// WaitForPodsToTerminateWithContext wait for all the pods matching the labels to terminate
+// or until the context is done
+func WaitForPodsToTerminateWithContext(ctx context.Context, k8sClient client.Client, namespace string, labels map[string]string) (err error) {
+	ctx, span := tracing.StartChild(ctx, "WaitForPodsToTerminate",
+		attribute.String("k8s.namespace", namespace))
+	defer func() { tracing.End(span, err) }()
 	listOptions := &client.ListOptions{
 		Namespace:     namespace,
 		LabelSelector: k8Labels.SelectorFromSet(labels),
 	}
-	err = wait.Poll(5*time.Second, 5*time.Minute, func() (done bool, err error) {
+	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, 5*time.Minute, false, func(ctx context.Context) (done bool, err error) {
 		podList := &v1.PodList{}
-		err = k8sClient.List(context.TODO(), podList, listOptions)
+		err = k8sClient.List(ctx, podList, listOptions)
 		if err != nil {
 			return false, err
 		}
@@ -18,8 +18,13 @@ package pdb
 
 import (
 	v1 "k8s.io/api/policy/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strconv"
+	"strings"
 )
 
 // +k8s:openapi-gen=true
@@ -59,3 +64,31 @@ func (in *PodDisruptionBudgetSpec) NewPodDisruptionBudget(name, namespace string
 		},
 	}
 }
+
+// Validate validates the spec with the rules the API server applies to the PodDisruptionBudget
+func (in *PodDisruptionBudgetSpec) Validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.MinAvailable != nil && in.MaxUnavailable != nil {
+		errs = append(errs, field.Forbidden(path.Child("maxUnavailable"), "minAvailable and maxUnavailable cannot be both set"))
+	}
+	errs = append(errs, validateIntOrPercent(path.Child("minAvailable"), in.MinAvailable)...)
+	return append(errs, validateIntOrPercent(path.Child("maxUnavailable"), in.MaxUnavailable)...)
+}
+
+// validateIntOrPercent checks the value is a non-negative integer or a percent not greater than 100%
+func validateIntOrPercent(path *field.Path, value *intstr.IntOrString) field.ErrorList {
+	var errs field.ErrorList
+	if value == nil {
+		return errs
+	}
+	if value.Type == intstr.Int {
+		return apivalidation.ValidateNonnegativeField(int64(value.IntValue()), path)
+	}
+	for _, msg := range validation.IsValidPercent(value.StrVal) {
+		errs = append(errs, field.Invalid(path, value.StrVal, msg))
+	}
+	if percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%")); err == nil && percent > 100 {
+		errs = append(errs, field.Invalid(path, value.StrVal, "must not be greater than 100%"))
+	}
+	return errs
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pdb
+
+import (
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"testing"
+)
+
+func TestPodDisruptionBudgetSpecValidate(t *testing.T) {
+	intOrPercent := func(value intstr.IntOrString) *intstr.IntOrString {
+		return &value
+	}
+	tests := []struct {
+		name string
+		spec PodDisruptionBudgetSpec
+		want []string
+	}{
+		{name: "empty"},
+		{name: "min available", spec: PodDisruptionBudgetSpec{MinAvailable: intOrPercent(intstr.FromInt(1))}},
+		{name: "max unavailable percent", spec: PodDisruptionBudgetSpec{MaxUnavailable: intOrPercent(intstr.FromString("50%"))}},
+		{
+			name: "both set",
+			spec: PodDisruptionBudgetSpec{
+				MinAvailable:   intOrPercent(intstr.FromInt(1)),
+				MaxUnavailable: intOrPercent(intstr.FromInt(1)),
+			},
+			want: []string{"Forbidden pdb.maxUnavailable"},
+		},
+		{
+			name: "negative min available",
+			spec: PodDisruptionBudgetSpec{MinAvailable: intOrPercent(intstr.FromInt(-1))},
+			want: []string{"Invalid value pdb.minAvailable"},
+		},
+		{
+			name: "percent over 100",
+			spec: PodDisruptionBudgetSpec{MaxUnavailable: intOrPercent(intstr.FromString("150%"))},
+			want: []string{"Invalid value pdb.maxUnavailable"},
+		},
+		{
+			name: "bad percent",
+			spec: PodDisruptionBudgetSpec{MinAvailable: intOrPercent(intstr.FromString("half"))},
+			want: []string{"Invalid value pdb.minAvailable"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range tt.spec.Validate(field.NewPath("pdb")) {
+				got = append(got, err.Type.String()+" "+err.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
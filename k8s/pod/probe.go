@@ -16,7 +16,11 @@
 
 package pod
 
-import v1 "k8s.io/api/core/v1"
+import (
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
 
 const (
 	// DefaultStartupProbeInitialDelaySeconds is the default  initial delay or the startup probe
@@ -182,3 +186,27 @@ func probeDefault(probe *Probe, delaySec, periodSec, failureThreshold, successTh
 	}
 	return
 }
+
+// Validate validates the probes; like the API server it requires the success threshold
+// of the liveness and startup probes to be 1
+func (in *Probes) Validate(path *field.Path) field.ErrorList {
+	errs := in.Startup.validate(path.Child("startup"), true)
+	errs = append(errs, in.Liveness.validate(path.Child("liveness"), true)...)
+	return append(errs, in.Readiness.validate(path.Child("readiness"), false)...)
+}
+
+func (in *Probe) validate(path *field.Path, singleSuccess bool) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return errs
+	}
+	errs = append(errs, apivalidation.ValidateNonnegativeField(int64(in.InitialDelaySeconds), path.Child("initialDelaySeconds"))...)
+	errs = append(errs, apivalidation.ValidateNonnegativeField(int64(in.PeriodSeconds), path.Child("periodSeconds"))...)
+	errs = append(errs, apivalidation.ValidateNonnegativeField(int64(in.FailureThreshold), path.Child("failureThreshold"))...)
+	errs = append(errs, apivalidation.ValidateNonnegativeField(int64(in.SuccessThreshold), path.Child("successThreshold"))...)
+	errs = append(errs, apivalidation.ValidateNonnegativeField(int64(in.TimeoutSeconds), path.Child("timeoutSeconds"))...)
+	if singleSuccess && in.SuccessThreshold > 1 {
+		errs = append(errs, field.Invalid(path.Child("successThreshold"), in.SuccessThreshold, "must be 1"))
+	}
+	return errs
+}
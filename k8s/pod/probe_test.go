@@ -0,0 +1,75 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pod
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"testing"
+)
+
+func TestProbesValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		probes Probes
+		want   []string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			probes: Probes{
+				Startup:   &Probe{PeriodSeconds: 5, FailureThreshold: 30, SuccessThreshold: 1},
+				Liveness:  &Probe{InitialDelaySeconds: 10, PeriodSeconds: 10, SuccessThreshold: 1, TimeoutSeconds: 5},
+				Readiness: &Probe{PeriodSeconds: 10, SuccessThreshold: 3},
+			},
+		},
+		{
+			name: "negative values",
+			probes: Probes{Readiness: &Probe{
+				InitialDelaySeconds: -1, PeriodSeconds: -1, FailureThreshold: -1, SuccessThreshold: -1, TimeoutSeconds: -1,
+			}},
+			want: []string{
+				"Invalid value probes.readiness.initialDelaySeconds",
+				"Invalid value probes.readiness.periodSeconds",
+				"Invalid value probes.readiness.failureThreshold",
+				"Invalid value probes.readiness.successThreshold",
+				"Invalid value probes.readiness.timeoutSeconds",
+			},
+		},
+		{
+			name:   "liveness success threshold",
+			probes: Probes{Liveness: &Probe{SuccessThreshold: 2}},
+			want:   []string{"Invalid value probes.liveness.successThreshold"},
+		},
+		{
+			name:   "startup success threshold",
+			probes: Probes{Startup: &Probe{SuccessThreshold: 2}},
+			want:   []string{"Invalid value probes.startup.successThreshold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range tt.probes.Validate(field.NewPath("probes")) {
+				got = append(got, err.Type.String()+" "+err.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
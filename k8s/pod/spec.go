@@ -78,6 +78,11 @@ func IsReady(pod *v1.Pod) bool {
 
 // ListAllWithMatchingLabels list the pods matching the labels
 func ListAllWithMatchingLabels(cl client.Client, namespace string, labels map[string]string) (*v1.PodList, error) {
+	return ListAllWithMatchingLabelsWithContext(context.TODO(), cl, namespace, labels)
+}
+
+// ListAllWithMatchingLabelsWithContext list the pods matching the labels
+func ListAllWithMatchingLabelsWithContext(ctx context.Context, cl client.Client, namespace string, labels map[string]string) (*v1.PodList, error) {
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
 		MatchLabels: labels,
 	})
@@ -89,7 +94,7 @@ func ListAllWithMatchingLabels(cl client.Client, namespace string, labels map[st
 		Namespace:     namespace,
 		LabelSelector: selector,
 	}
-	err = cl.List(context.TODO(), list, listOpts)
+	err = cl.List(ctx, list, listOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +103,12 @@ func ListAllWithMatchingLabels(cl client.Client, namespace string, labels map[st
 
 // ListAllWithMatchingLabelsByReadiness list the pods matching the labels
 func ListAllWithMatchingLabelsByReadiness(cl client.Client, namespace string, labels map[string]string) (ready []v1.Pod, unready []v1.Pod, err error) {
-	pods, err0 := ListAllWithMatchingLabels(cl, namespace, labels)
+	return ListAllWithMatchingLabelsByReadinessWithContext(context.TODO(), cl, namespace, labels)
+}
+
+// ListAllWithMatchingLabelsByReadinessWithContext list the pods matching the labels
+func ListAllWithMatchingLabelsByReadinessWithContext(ctx context.Context, cl client.Client, namespace string, labels map[string]string) (ready []v1.Pod, unready []v1.Pod, err error) {
+	pods, err0 := ListAllWithMatchingLabelsWithContext(ctx, cl, namespace, labels)
 	if err0 != nil {
 		err = err0
 		return
@@ -43,6 +43,11 @@ func New(namespace, name string, labels map[string]string, spec v1.PersistentVol
 
 // ListAllWithMatchingLabels list the pvcs matching the labels
 func ListAllWithMatchingLabels(cl client.Client, namespace string, labels map[string]string) (*v1.PersistentVolumeClaimList, error) {
+	return ListAllWithMatchingLabelsWithContext(context.TODO(), cl, namespace, labels)
+}
+
+// ListAllWithMatchingLabelsWithContext list the pvcs matching the labels
+func ListAllWithMatchingLabelsWithContext(ctx context.Context, cl client.Client, namespace string, labels map[string]string) (*v1.PersistentVolumeClaimList, error) {
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
 		MatchLabels: labels,
 	})
@@ -54,7 +59,7 @@ func ListAllWithMatchingLabels(cl client.Client, namespace string, labels map[st
 		Namespace:     namespace,
 		LabelSelector: selector,
 	}
-	err = cl.List(context.TODO(), list, listOpts)
+	err = cl.List(ctx, list, listOpts)
 	if err != nil {
 		return nil, err
 	}
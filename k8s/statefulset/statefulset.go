@@ -63,8 +63,13 @@ func New(namespace, name string, labels map[string]string, spec v1.StatefulSetSp
 
 // IsReady checks if a statefulset is ready by comparing the desired replicas to the ready replicas
 func IsReady(client client.Client, namespace, name string, replicas int32) bool {
+	return IsReadyWithContext(context.TODO(), client, namespace, name, replicas)
+}
+
+// IsReadyWithContext checks if a statefulset is ready by comparing the desired replicas to the ready replicas
+func IsReadyWithContext(ctx context.Context, client client.Client, namespace, name string, replicas int32) bool {
 	sset := &v1.StatefulSet{}
-	err := client.Get(context.TODO(), types.NamespacedName{
+	err := client.Get(ctx, types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, sset)
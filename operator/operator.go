@@ -17,7 +17,9 @@
 package operator
 
 import (
+	"context"
 	"fmt"
+	"github.com/monimesl/operator-helper/config"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/operator-helper/webhook"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -26,9 +28,10 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"time"
 )
 
-// Start configures
+// Start configures the health checks and the tracing set in the env then starts the manager
 func Start(mgr manager.Manager) error {
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		return err
@@ -36,7 +39,18 @@ func Start(mgr manager.Manager) error {
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		return err
 	}
-	return mgr.Start(ctrl.SetupSignalHandler())
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := config.SetupTracing(ctx, config.OperatorName())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// flush the pending spans
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(flushCtx)
+	}()
+	return mgr.Start(ctx)
 }
 
 // BootOrDie configures...
@@ -47,18 +61,26 @@ func BootOrDie(config *rest.Config, options ctrl.Options, getReconcilers func()
 }
 
 // Boot configures...
-func Boot(config *rest.Config, options ctrl.Options, getReconcilers func() []reconciler.Reconciler, getRuntimeObjs func() []runtime.Object) error {
-	mgr, err := manager.New(config, options)
+func Boot(restConfig *rest.Config, options ctrl.Options, getReconcilers func() []reconciler.Reconciler, getRuntimeObjs func() []runtime.Object) error {
+	reconciler.RegisterPlanEndpoint(&options)
+	mgr, err := manager.New(restConfig, options)
 	if err != nil {
 		return fmt.Errorf("manager create error: %w", err)
 	}
+	// the webhooks and the reconcilers share the Context
+	ctx := reconciler.NewContext(mgr)
 	if getRuntimeObjs != nil {
-		if err = webhook.Configure(mgr, getRuntimeObjs()...); err != nil {
+		if config.WebHooksEnabled() {
+			if err = webhook.SetupCertProvisioning(mgr); err != nil {
+				return fmt.Errorf("webhook certificates error: %w", err)
+			}
+		}
+		if err = webhook.ConfigureWithContext(ctx, getRuntimeObjs()...); err != nil {
 			return fmt.Errorf("webhook config error: %w", err)
 		}
 	}
 	if getReconcilers != nil {
-		if err = reconciler.Configure(mgr, getReconcilers()...); err != nil {
+		if err = reconciler.ConfigureWithContext(ctx, getReconcilers()...); err != nil {
 			return fmt.Errorf("reconciler config error: %w", err)
 		}
 	}
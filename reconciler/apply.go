@@ -0,0 +1,86 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/drift"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+func (c *contextImpl) Apply(ctx context.Context, owner metav1.Object, desired client.Object) (changed bool, err error) {
+	gvk, err := apiutil.GVKForObject(desired, c.Scheme())
+	if err != nil {
+		return false, err
+	}
+	if err = c.SetOwnershipReference(owner, desired); err != nil {
+		return false, err
+	}
+	// the apply configuration must carry the type and no version to compare against
+	desired.GetObjectKind().SetGroupVersionKind(gvk)
+	desired.SetResourceVersion("")
+	desired.SetManagedFields(nil)
+	key := client.ObjectKeyFromObject(desired)
+	existing := desired.DeepCopyObject().(client.Object)
+	if err = c.Client().Get(ctx, key, existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		existing = nil
+	}
+	var report drift.Report
+	if existing != nil {
+		if report, err = drift.Detect(desired, existing); err != nil {
+			return false, err
+		}
+	}
+	err = c.Client().Patch(ctx, desired, client.Apply,
+		client.FieldOwner(c.fieldManager), client.ForceOwnership)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		c.LoggerFrom(ctx).Info("Created the object", "kind", gvk.Kind, "object", key)
+		return true, nil
+	}
+	if report.NeedsUpdate() {
+		c.LoggerFrom(ctx).Info("Corrected the drift of the object", "kind", gvk.Kind, "object", key,
+			"paths", report.Paths, "diff", report.Diff)
+		observeDriftCorrection(gvk.Kind)
+	}
+	if !equalIgnoringVersion(existing, desired) {
+		c.LoggerFrom(ctx).Info("Updated the object", "kind", gvk.Kind, "object", key)
+		return true, nil
+	}
+	return false, nil
+}
+
+// equalIgnoringVersion checks if the objects are equal apart from the
+// metadata the server bumps on every write even when nothing changed
+func equalIgnoringVersion(a, b client.Object) bool {
+	a = a.DeepCopyObject().(client.Object)
+	b = b.DeepCopyObject().(client.Object)
+	for _, obj := range []client.Object{a, b} {
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+	}
+	return equality.Semantic.DeepEqual(a, b)
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ConditionReady indicates the object is fully reconciled and serving
+	ConditionReady = "Ready"
+	// ConditionProgressing indicates the object is transitioning to its desired state
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded indicates the object is running with a reduced functionality
+	ConditionDegraded = "Degraded"
+)
+
+const (
+	// ReasonReconcileFailed is the reason of the Ready condition set by Run when the reconcile function fails
+	ReasonReconcileFailed = "ReconcileFailed"
+	// ReasonReconciling is the reason of the Ready condition set by Run when a failed reconciliation recovers
+	ReasonReconciling = "Reconciling"
+)
+
+// ConditionsAware defines interface for the kubernetes object whose status holds standard conditions
+type ConditionsAware interface {
+	KubeRuntimeObject
+
+	// GetConditions returns the conditions of the object status
+	GetConditions() []metav1.Condition
+
+	// SetConditions replaces the conditions of the object status
+	SetConditions(conditions []metav1.Condition)
+}
+
+// NewCondition creates a new condition of the specified type
+func NewCondition(condType string, status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    condType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// SetCondition sets the condition on the object replacing any existing one of the same type.
+// The LastTransitionTime is changed only when the condition status changes and the
+// ObservedGeneration defaults to the object generation. Returns true if anything changed
+func SetCondition(object ConditionsAware, condition metav1.Condition) (changed bool) {
+	if condition.ObservedGeneration == 0 {
+		condition.ObservedGeneration = object.GetGeneration()
+	}
+	conditions := object.GetConditions()
+	existing := FindCondition(object, condition.Type)
+	if existing != nil {
+		existing = existing.DeepCopy()
+	}
+	meta.SetStatusCondition(&conditions, condition)
+	object.SetConditions(conditions)
+	return existing == nil || !equality.Semantic.DeepEqual(*existing, *FindCondition(object, condition.Type))
+}
+
+// RemoveCondition removes the condition of the specified type from the object. Returns true if it existed
+func RemoveCondition(object ConditionsAware, condType string) (removed bool) {
+	conditions := object.GetConditions()
+	if meta.FindStatusCondition(conditions, condType) == nil {
+		return false
+	}
+	meta.RemoveStatusCondition(&conditions, condType)
+	object.SetConditions(conditions)
+	return true
+}
+
+// FindCondition finds the condition of the specified type or returns nil if none exists
+func FindCondition(object ConditionsAware, condType string) *metav1.Condition {
+	return meta.FindStatusCondition(object.GetConditions(), condType)
+}
+
+// IsConditionTrue checks if the condition of the specified type has the status True
+func IsConditionTrue(object ConditionsAware, condType string) bool {
+	return meta.IsStatusConditionTrue(object.GetConditions(), condType)
+}
+
+func (c *contextImpl) SetConditions(ctx context.Context, object ConditionsAware, conditions ...metav1.Condition) error {
+	return c.patchWithRetry(ctx, object, true, func(obj client.Object) bool {
+		changed := false
+		for _, condition := range conditions {
+			if SetCondition(obj.(ConditionsAware), condition) {
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// setFailedCondition marks the object not ready with the reconcile error
+func (c *contextImpl) setFailedCondition(ctx context.Context, object KubeRuntimeObject, err error) {
+	if ca, ok := object.(ConditionsAware); ok {
+		condition := NewCondition(ConditionReady, metav1.ConditionFalse, ReasonReconcileFailed, err.Error())
+		if err0 := c.SetConditions(ctx, ca, condition); err0 != nil {
+			c.Logger().Error(err0, "Failed to set the Ready condition of the request object")
+		}
+	}
+}
+
+// clearFailedCondition resets the Ready condition set by setFailedCondition once a reconciliation succeeds
+func (c *contextImpl) clearFailedCondition(ctx context.Context, object KubeRuntimeObject) {
+	if _, ok := object.(ConditionsAware); !ok {
+		return
+	}
+	err := c.patchWithRetry(ctx, object, true, func(obj client.Object) bool {
+		// checked on every attempt as a conflicting writer may have already reset the condition
+		ca := obj.(ConditionsAware)
+		ready := FindCondition(ca, ConditionReady)
+		if ready == nil || ready.Reason != ReasonReconcileFailed {
+			return false
+		}
+		return SetCondition(ca, NewCondition(ConditionReady, metav1.ConditionUnknown, ReasonReconciling, ""))
+	})
+	if err != nil {
+		c.Logger().Error(err, "Failed to reset the Ready condition of the request object")
+	}
+}
@@ -19,12 +19,20 @@ package reconciler
 import (
 	"context"
 	"github.com/go-logr/logr"
+	"github.com/monimesl/operator-helper/config"
+	"github.com/monimesl/operator-helper/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -36,29 +44,126 @@ var (
 	instance Context
 )
 
+// GetContext returns the Context of the last call of Configure or ConfigureWithContext or panic if none
+// Deprecated. New code should keep the Context passed to Reconciler.Configure
 func GetContext() Context {
 	if instance == nil {
-		panic("No context instance. Call NewContext(mgr manager.Manager) to create the instance")
+		panic("No context instance. Call Configure(mgr manager.Manager, ...) to create the instance")
 	}
 	return instance
 }
 
-// NewContext creates a new reconciler Context
-func NewContext(mgr manager.Manager) Context {
-	instance = &contextImpl{manager: mgr}
-	return instance
+// NewContext creates a new reconciler Context of the manager. Each Context is independent
+// so multiple managers can run in the same process
+func NewContext(mgr manager.Manager, options ...ContextOption) Context {
+	opts := &contextOptions{
+		fieldManager: operatorName(),
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+	logger := mgr.GetLogger()
+	if opts.loggerName != "" {
+		logger = logger.WithName(opts.loggerName)
+	}
+	recorder := opts.recorder
+	if recorder == nil {
+		recorder = mgr.GetEventRecorderFor(opts.fieldManager)
+	}
+	plan := opts.plan
+	if plan == nil && config.DryRunEnabled() {
+		plan = DefaultPlan
+	}
+	provider := opts.tracerProvider
+	if provider == nil && config.TracingEnabled() {
+		provider = otel.GetTracerProvider()
+	}
+	var tracer trace.Tracer
+	if provider != nil {
+		tracer = provider.Tracer(tracing.InstrumentationName)
+	}
+	return &contextImpl{
+		manager:      mgr,
+		logger:       logger,
+		recorder:     recorder,
+		events:       newEventThrottle(DefaultEventThrottleInterval),
+		fieldManager: opts.fieldManager,
+		plan:         plan,
+		tracer:       tracer,
+	}
 }
 
 type contextImpl struct {
-	manager manager.Manager
+	manager      manager.Manager
+	logger       logr.Logger
+	recorder     record.EventRecorder
+	events       *eventThrottle
+	fieldManager string
+	plan         *Plan
+	tracer       trace.Tracer
+}
+
+func operatorName() string {
+	if name := config.OperatorName(); name != "" {
+		return name
+	}
+	return config.DefaultOperatorName
 }
 
 func (c *contextImpl) Logger() logr.Logger {
-	return c.manager.GetLogger()
+	return c.logger
+}
+
+func (c *contextImpl) LoggerFrom(ctx context.Context) logr.Logger {
+	if logger, err := logr.FromContext(ctx); err == nil {
+		return logger
+	}
+	return c.logger
+}
+
+// forRequest returns a copy of the Context logging with the per-reconcile logger of the request and the
+// reconcile ID. The logger and the ID controller-runtime puts in the ctx are kept so the logs match those
+// of the controller; they are otherwise created for the calls outside of a controller e.g. in tests
+func (c *contextImpl) forRequest(ctx context.Context, req reconcile.Request) (*contextImpl, string) {
+	rc := *c
+	reconcileID := string(controller.ReconcileIDFromContext(ctx))
+	logger, err := logr.FromContext(ctx)
+	if err == nil && reconcileID != "" {
+		// the controller logger carries the request and the reconcile ID
+		rc.logger = logger
+		return &rc, reconcileID
+	}
+	if err != nil {
+		logger = c.logger
+	}
+	if reconcileID == "" {
+		reconcileID = string(uuid.NewUUID())
+	}
+	rc.logger = logger.WithValues(
+		"namespace", req.Namespace,
+		"name", req.Name,
+		"reconcileID", reconcileID,
+	)
+	return &rc, reconcileID
+}
+
+func (c *contextImpl) Recorder() record.EventRecorder {
+	return c.recorder
 }
 
 func (c *contextImpl) Client() client.Client {
-	return c.manager.GetClient()
+	cl := c.manager.GetClient()
+	if c.plan != nil {
+		cl = &dryRunClient{Client: cl, plan: c.plan, logger: c.logger}
+	}
+	if c.tracer != nil {
+		cl = &tracingClient{Client: cl}
+	}
+	return cl
+}
+
+func (c *contextImpl) DryRun() bool {
+	return c.plan != nil
 }
 
 func (c *contextImpl) Scheme() *runtime.Scheme {
@@ -69,6 +174,18 @@ func (c *contextImpl) NewControllerBuilder() *builder.Builder {
 	return ctrl.NewControllerManagedBy(c.manager)
 }
 
+func (c *contextImpl) NewWebhookBuilder() *builder.WebhookBuilder {
+	return ctrl.NewWebhookManagedBy(c.manager)
+}
+
+func (c *contextImpl) FieldIndexer() client.FieldIndexer {
+	return c.manager.GetFieldIndexer()
+}
+
+func (c *contextImpl) FieldManager() string {
+	return c.fieldManager
+}
+
 func (c *contextImpl) SetOwnershipReference(owner metav1.Object, controlled metav1.Object) error {
 	c.Logger().Info("Setting ownership reference to an object",
 		"object", controlled.GetName(), "owner", owner.GetName())
@@ -76,10 +193,14 @@ func (c *contextImpl) SetOwnershipReference(owner metav1.Object, controlled meta
 }
 
 func (c *contextImpl) GetResource(key client.ObjectKey, object client.Object, foundCallback func() (err error), notFoundCallback func() (err error)) (err error) {
+	return c.GetResourceWithContext(context.TODO(), key, object, foundCallback, notFoundCallback)
+}
+
+func (c *contextImpl) GetResourceWithContext(ctx context.Context, key client.ObjectKey, object client.Object, foundCallback func() (err error), notFoundCallback func() (err error)) (err error) {
 	if foundCallback == nil && notFoundCallback == nil {
 		panic("Cannot have both un/found callbacks be nil")
 	}
-	err = c.Client().Get(context.TODO(), key, object)
+	err = c.Client().Get(ctx, key, object)
 	if err == nil && foundCallback != nil {
 		return foundCallback()
 	} else if errors.IsNotFound(err) {
@@ -91,78 +212,153 @@ func (c *contextImpl) GetResource(key client.ObjectKey, object client.Object, fo
 	return
 }
 
-func (c *contextImpl) Run(req reconcile.Request, object KubeRuntimeObject, reconcile func(deleted bool) error) (reconcile.Result, error) {
+func (c *contextImpl) Run(req reconcile.Request, object KubeRuntimeObject, reconcile func(deleted bool) error, options ...RunOption) (reconcile.Result, error) {
+	return c.RunWithResult(req, object, func(deleted bool) (Result, error) {
+		return Done(), reconcile(deleted)
+	}, options...)
+}
+
+func (c *contextImpl) RunWithResult(req reconcile.Request, object KubeRuntimeObject, reconcile func(deleted bool) (Result, error), options ...RunOption) (reconcile.Result, error) {
+	return c.RunWithContext(context.Background(), req, object, func(_ context.Context, deleted bool) (Result, error) {
+		return reconcile(deleted)
+	}, options...)
+}
+
+func (c *contextImpl) RunWithContext(ctx context.Context, req reconcile.Request, object KubeRuntimeObject, reconcile func(ctx context.Context, deleted bool) (Result, error), options ...RunOption) (reconcile.Result, error) {
+	opts := newRunOptions(options)
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	kind := kindOf(object, c.Scheme())
+	rc, reconcileID := c.forRequest(ctx, req)
+	ctx = logr.NewContext(ctx, rc.logger)
+	ctx, span := tracing.Start(ctx, c.tracer, kind,
+		attribute.String("k8s.namespace", req.Namespace),
+		attribute.String("k8s.name", req.Name),
+		attribute.String("reconcileID", reconcileID),
+	)
 	startTime := time.Now()
-	start(req, c.Logger())
-	defer end(req, startTime, c.Logger())
-	if err := c.Client().Get(context.TODO(), req.NamespacedName, object); err != nil {
+	start(rc.logger)
+	defer end(startTime, rc.logger)
+	result, err := rc.run(ctx, req, kind, object, reconcile, opts)
+	observeReconcile(kind, time.Since(startTime), err)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (c *contextImpl) run(ctx context.Context, req reconcile.Request, kind string, object KubeRuntimeObject, reconcile func(ctx context.Context, deleted bool) (Result, error), opts *runOptions) (reconcile.Result, error) {
+	if err := c.Client().Get(ctx, req.NamespacedName, object); err != nil {
 		if errors.IsNotFound(err) {
 			// The runtime object is not found. Kubernetes will automatically
-			// garbage collect all owned resources - return but do not requeue
-			return complete(req, c.Logger())
+			// garbage collect all owned resources - complete without requeue
+			forgetObject(kind, req.NamespacedName)
+			return complete(c.Logger())
 		}
-		// Read error; requeue here
-		return errored(err, req, c.Logger())
+		// Read error; retried with backoff
+		return errored(err, c.Logger())
 	}
 	if delTime := object.GetDeletionTimestamp(); delTime != nil {
 		c.Logger().Info("The request object has been scheduled for delete",
 			"Timestamp", time.Until(delTime.Time).Seconds())
-		// The runtime object is marked for deletion - return but do not requeue
-		if err := reconcile(true); err != nil {
-			return errored(err, req, c.Logger())
+		observeDeletion(kind)
+		if opts.finalizer != "" && !controllerutil.ContainsFinalizer(object, opts.finalizer) {
+			// The cleanup has already been done - complete without requeue
+			return complete(c.Logger())
 		}
-		return complete(req, c.Logger())
-	}
-
-	if df, ok := object.(Defaulting); ok && df.SetSpecDefaults() {
-		c.Logger().Info("Setting the default spec of the request object")
-		if err := c.Client().Update(context.TODO(), object); err != nil {
-			return errored(err, req, c.Logger())
+		// The runtime object is marked for deletion - clean up, then complete
+		// or requeue as the cleanup result or error tells
+		result, err := reconcile(ctx, true)
+		if err != nil {
+			// The finalizer is kept so the cleanup is retried with backoff
+			c.failed(ctx, object, EventReasonCleanupFailed, err)
+			return errored(err, c.Logger())
+		}
+		if !result.IsDone() {
+			// The cleanup is still in progress; keep the finalizer
+			return requeued(result, c.Logger())
+		}
+		if opts.finalizer != "" {
+			c.Logger().Info("Removing the finalizer of the request object", "finalizer", opts.finalizer)
+			if err := c.removeFinalizer(ctx, object, opts.finalizer); err != nil {
+				return errored(err, c.Logger())
+			}
+			c.NormalEvent(object, EventReasonCleanupCompleted, "Cleanup completed, removed the finalizer: %s", opts.finalizer)
+		} else {
+			c.NormalEvent(object, EventReasonCleanupCompleted, "Cleanup completed")
 		}
+		forgetObject(kind, req.NamespacedName)
+		return complete(c.Logger())
 	}
-	if df, ok := object.(Defaulting); ok && df.SetStatusDefaults() {
-		c.Logger().Info("Setting the default status of the request object")
-		if err := c.Client().Status().Update(context.TODO(), object); err != nil {
-			return errored(err, req, c.Logger())
+	// A paused object is only written to for its Paused condition
+	paused := IsPaused(object)
+	recordPause(kind, req.NamespacedName, paused)
+	if err := c.setPausedCondition(ctx, object, paused); err != nil {
+		return errored(err, c.Logger())
+	}
+	if paused {
+		c.Logger().Info("The reconciliation of the request object is paused",
+			"annotation", AnnotationPaused)
+		return complete(c.Logger())
+	}
+	if opts.finalizer != "" && !controllerutil.ContainsFinalizer(object, opts.finalizer) {
+		c.Logger().Info("Adding the finalizer to the request object", "finalizer", opts.finalizer)
+		if err := c.addFinalizer(ctx, object, opts.finalizer); err != nil {
+			return errored(err, c.Logger())
 		}
 	}
-	if err := reconcile(false); err != nil {
-		return errored(err, req, c.Logger())
+
+	if err := c.setDefaults(ctx, object); err != nil {
+		return errored(err, c.Logger())
+	}
+	result, err := reconcile(ctx, false)
+	if err != nil {
+		c.failed(ctx, object, EventReasonReconcileFailed, err)
+		recordReadiness(kind, req.NamespacedName, object)
+		return errored(err, c.Logger())
 	}
-	return complete(req, c.Logger())
+	c.clearFailedCondition(ctx, object)
+	recordReadiness(kind, req.NamespacedName, object)
+	if err = c.setObservedGeneration(ctx, object); err != nil {
+		return errored(err, c.Logger())
+	}
+	if !result.IsDone() {
+		return requeued(result, c.Logger())
+	}
+	return complete(c.Logger())
 }
 
-func complete(req reconcile.Request, logger logr.Logger) (reconcile.Result, error) {
-	logger.Info("[Complete] Reconciliation",
-		"Request.Namespace",
-		req.NamespacedName, "Request.Name",
-		req.Name,
-	)
+// failed reports the reconcile function error on the object status and events
+func (c *contextImpl) failed(ctx context.Context, object KubeRuntimeObject, eventReason string, err error) {
+	c.setFailedCondition(ctx, object, err)
+	c.WarningEvent(object, eventReason, "%s", err)
+}
+
+func complete(logger logr.Logger) (reconcile.Result, error) {
+	logger.Info("[Complete] Reconciliation")
 	return reconcile.Result{Requeue: false}, nil
 }
 
-func errored(err error, req reconcile.Request, logger logr.Logger) (reconcile.Result, error) {
-	logger.Error(err, "[Error] Reconciliation",
-		"Request.Namespace",
-		req.NamespacedName, "Request.Name",
-		req.Name,
-	)
-	return reconcile.Result{Requeue: true}, err
+func requeued(result Result, logger logr.Logger) (reconcile.Result, error) {
+	logger.Info("[Requeue] Reconciliation", "afterSec", result.requeueAfter.Seconds())
+	return result.toReconcileResult(), nil
 }
 
-func start(req reconcile.Request, logger logr.Logger) {
-	logger.Info("[Start] Reconciliation",
-		"Request.Namespace",
-		req.Namespace, "Request.Name",
-		req.Name,
-	)
+func errored(err error, logger logr.Logger) (reconcile.Result, error) {
+	logger.Error(err, "[Error] Reconciliation", "terminal", IsTerminal(err))
+	if IsTerminal(err) {
+		// reported but not retried
+		return reconcile.Result{}, reconcile.TerminalError(err)
+	}
+	// the controller requeues the request with backoff
+	return reconcile.Result{}, err
 }
 
-func end(req reconcile.Request, startTime time.Time, logger logr.Logger) {
-	duration := time.Since(startTime).Seconds()
-	logger.Info("[End] Reconciliation",
-		"Request.Namespace",
-		req.NamespacedName, "Request.Name",
-		req.Name, "durationSec", duration,
-	)
+func start(logger logr.Logger) {
+	logger.Info("[Start] Reconciliation")
+}
+
+func end(startTime time.Time, logger logr.Logger) {
+	logger.Info("[End] Reconciliation", "durationSec", time.Since(startTime).Seconds())
 }
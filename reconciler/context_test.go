@@ -0,0 +1,240 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler_test
+
+import (
+	"context"
+	"errors"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/operator-helper/reconciler/reconcilertest"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFinalizer = "test.example.com/cleanup"
+
+// cleanupReconciler cleans up the ConfigMap on deletion with the configured outcome
+type cleanupReconciler struct {
+	ctx           reconciler.Context
+	cleanupResult reconciler.Result
+	cleanupErr    error
+	cleanups      int
+}
+
+func (r *cleanupReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	return r.ctx.RunWithContext(ctx, req, &v1.ConfigMap{}, func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+		if !deleted {
+			return reconciler.Done(), nil
+		}
+		r.cleanups++
+		return r.cleanupResult, r.cleanupErr
+	}, reconciler.WithFinalizer(testFinalizer))
+}
+
+func TestRunFinalizer(t *testing.T) {
+	key := types.NamespacedName{Namespace: "test", Name: "test"}
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+	h := reconcilertest.New(t, clientgoscheme.Scheme, cm)
+	r := &cleanupReconciler{ctx: h.Context()}
+	steps := []struct {
+		name          string
+		delete        bool
+		cleanupResult reconciler.Result
+		cleanupErr    error
+		wantErr       bool
+		wantResult    reconcile.Result
+		wantEventType string
+		wantEvent     string
+		wantCleanups  int
+		wantFinalizer bool
+		wantGone      bool
+	}{
+		{
+			name:          "finalizer added",
+			wantFinalizer: true,
+		},
+		{
+			name:          "finalizer kept",
+			wantFinalizer: true,
+		},
+		{
+			name:          "cleanup failed",
+			delete:        true,
+			cleanupErr:    errors.New("backup in progress"),
+			wantErr:       true,
+			wantEventType: v1.EventTypeWarning,
+			wantEvent:     reconciler.EventReasonCleanupFailed,
+			wantCleanups:  1,
+			wantFinalizer: true,
+		},
+		{
+			name:          "cleanup in progress",
+			cleanupResult: reconciler.RequeueAfter(time.Minute),
+			wantResult:    reconcile.Result{Requeue: true, RequeueAfter: time.Minute},
+			wantCleanups:  2,
+			wantFinalizer: true,
+		},
+		{
+			name:          "cleanup completed",
+			cleanupResult: reconciler.Done(),
+			wantEventType: v1.EventTypeNormal,
+			wantEvent:     reconciler.EventReasonCleanupCompleted,
+			wantCleanups:  3,
+			wantGone:      true,
+		},
+		{
+			name:         "already deleted",
+			wantCleanups: 3,
+			wantGone:     true,
+		},
+	}
+	for _, step := range steps {
+		if step.delete {
+			if err := h.Client().Delete(context.Background(), cm); err != nil {
+				t.Fatalf("%s: delete error: %v", step.name, err)
+			}
+		}
+		r.cleanupResult, r.cleanupErr = step.cleanupResult, step.cleanupErr
+		outcome := h.Reconcile(r, key)
+		if (outcome.Err != nil) != step.wantErr {
+			t.Fatalf("%s: expected error: %v, got: %v", step.name, step.wantErr, outcome.Err)
+		}
+		outcome.AssertResult(step.wantResult)
+		if step.wantEvent != "" {
+			outcome.AssertEvent(step.wantEventType, step.wantEvent)
+		}
+		if r.cleanups != step.wantCleanups {
+			t.Errorf("%s: expected %d cleanups, got: %d", step.name, step.wantCleanups, r.cleanups)
+		}
+		live := &v1.ConfigMap{}
+		err := h.Client().Get(context.Background(), key, live)
+		if gone := apierrors.IsNotFound(err); gone != step.wantGone {
+			t.Fatalf("%s: expected gone: %v, got: %v", step.name, step.wantGone, err)
+		}
+		if step.wantGone {
+			continue
+		}
+		if has := controllerutil.ContainsFinalizer(live, testFinalizer); has != step.wantFinalizer {
+			t.Errorf("%s: expected the finalizer: %v, got: %v", step.name, step.wantFinalizer, live.Finalizers)
+		}
+	}
+}
+
+func TestRunPaused(t *testing.T) {
+	key := types.NamespacedName{Namespace: "test", Name: "test"}
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   key.Namespace,
+		Name:        key.Name,
+		Annotations: map[string]string{reconciler.AnnotationPaused: "true"},
+	}}
+	h := reconcilertest.New(t, clientgoscheme.Scheme, cm)
+	reconciles := 0
+	r := reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		return h.Context().RunWithContext(ctx, req, &v1.ConfigMap{}, func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+			reconciles++
+			return reconciler.Done(), nil
+		}, reconciler.WithFinalizer(testFinalizer))
+	})
+	h.Reconcile(r, key).AssertNoError().AssertNotWritten(cm)
+	if reconciles != 0 {
+		t.Errorf("expected no reconcile of the paused object, got: %d", reconciles)
+	}
+
+	live := &v1.ConfigMap{}
+	if err := h.Client().Get(context.Background(), key, live); err != nil {
+		t.Fatal(err)
+	}
+	delete(live.Annotations, reconciler.AnnotationPaused)
+	if err := h.Client().Update(context.Background(), live); err != nil {
+		t.Fatal(err)
+	}
+	h.Reconcile(r, key).AssertNoError().AssertUpdated(live)
+	if reconciles != 1 || !controllerutil.ContainsFinalizer(live, testFinalizer) {
+		t.Errorf("expected the resumed object reconciled with the finalizer, got: %d %v", reconciles, live.Finalizers)
+	}
+}
+
+func TestRunDeletionWithoutFinalizer(t *testing.T) {
+	key := types.NamespacedName{Namespace: "test", Name: "test"}
+	now := metav1.Now()
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace:         key.Namespace,
+		Name:              key.Name,
+		DeletionTimestamp: &now,
+		// kept by another controller
+		Finalizers: []string{"other.example.com/finalizer"},
+	}}
+	h := reconcilertest.New(t, clientgoscheme.Scheme, cm)
+	cleanups := 0
+	h.Reconcile(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		return h.Context().RunWithContext(ctx, req, &v1.ConfigMap{}, func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+			if deleted {
+				cleanups++
+			}
+			return reconciler.Done(), nil
+		})
+	}), key).
+		AssertNoError().
+		AssertEvent(v1.EventTypeNormal, reconciler.EventReasonCleanupCompleted).
+		AssertNotWritten(cm)
+	if cleanups != 1 {
+		t.Errorf("expected one cleanup, got: %d", cleanups)
+	}
+}
+
+func TestRunWithContextLogger(t *testing.T) {
+	key := types.NamespacedName{Namespace: "test", Name: "test"}
+	h := reconcilertest.New(t, clientgoscheme.Scheme,
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}})
+	var lines []string
+	logger := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{}).WithValues("controller", "configmap")
+	ctx := logr.NewContext(context.Background(), logger)
+	_, err := h.Context().RunWithContext(ctx, reconcile.Request{NamespacedName: key}, &v1.ConfigMap{},
+		func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+			h.Context().LoggerFrom(ctx).Info("reconciling")
+			return reconciler.Done(), nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, line := range lines {
+		if strings.Contains(line, `"msg"="reconciling"`) {
+			found = true
+			for _, value := range []string{`"controller"="configmap"`, `"name"="test"`, `"reconcileID"=`} {
+				if !strings.Contains(line, value) {
+					t.Errorf("expected the log to carry %s, got: %s", value, line)
+				}
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected the reconcile log in the logger of the ctx, got: %v", lines)
+	}
+}
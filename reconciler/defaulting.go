@@ -0,0 +1,49 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setDefaults patches the spec and then the status defaults of a Defaulting object.
+// Each patch is computed from the object before and after setting the defaults and is
+// retried on conflict with the defaults set again on the re-fetched object. The object
+// is left with the patched version so the reconciliation can continue with it
+func (c *contextImpl) setDefaults(ctx context.Context, object KubeRuntimeObject) error {
+	if _, ok := object.(Defaulting); !ok {
+		return nil
+	}
+	err := c.patchWithRetry(ctx, object, false, func(obj client.Object) bool {
+		if obj.(Defaulting).SetSpecDefaults() {
+			c.Logger().Info("Setting the default spec of the request object")
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	return c.patchWithRetry(ctx, object, true, func(obj client.Object) bool {
+		if obj.(Defaulting).SetStatusDefaults() {
+			c.Logger().Info("Setting the default status of the request object")
+			return true
+		}
+		return false
+	})
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// EventReasonReconcileFailed is the reason of the Warning event recorded by Run when the reconcile function fails
+	EventReasonReconcileFailed = "ReconcileFailed"
+	// EventReasonCleanupFailed is the reason of the Warning event recorded by Run when the deletion callback fails
+	EventReasonCleanupFailed = "CleanupFailed"
+	// EventReasonCleanupCompleted is the reason of the Normal event recorded by Run when the deletion callback succeeds
+	EventReasonCleanupCompleted = "CleanupCompleted"
+)
+
+// DefaultEventThrottleInterval is the interval within which an event of a type and reason of an object is recorded once
+const DefaultEventThrottleInterval = 5 * time.Minute
+
+func (c *contextImpl) NormalEvent(object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	c.recordEvent(object, v1.EventTypeNormal, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (c *contextImpl) WarningEvent(object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	c.recordEvent(object, v1.EventTypeWarning, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (c *contextImpl) recordEvent(object runtime.Object, eventType, reason, message string) {
+	if c.DryRun() {
+		c.logger.Info("Skipped the event in dry-run mode", "type", eventType, "reason", reason, "message", message)
+		return
+	}
+	if c.events.allow(object, eventType, reason) {
+		c.Recorder().Event(object, eventType, reason, message)
+	}
+}
+
+// eventThrottle drops an event of the type and reason of one recorded for the same object within the interval
+type eventThrottle struct {
+	interval time.Duration
+	mu       sync.Mutex
+	recorded map[string]time.Time
+}
+
+func newEventThrottle(interval time.Duration) *eventThrottle {
+	return &eventThrottle{
+		interval: interval,
+		recorded: map[string]time.Time{},
+	}
+}
+
+// allow checks if the event can be recorded. The message is not part of the key so the events
+// of e.g. an error whose message carries a resourceVersion or a timestamp are throttled too
+func (t *eventThrottle) allow(object runtime.Object, eventType, reason string) bool {
+	key := fmt.Sprintf("%s/%s", eventType, reason)
+	if accessor, err := meta.Accessor(object); err == nil {
+		id := string(accessor.GetUID())
+		if id == "" {
+			id = accessor.GetNamespace() + "/" + accessor.GetName()
+		}
+		key = fmt.Sprintf("%s/%s", id, key)
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.recorded[key]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	for k, last := range t.recorded {
+		// evict the expired entries to bound the map size
+		if now.Sub(last) >= t.interval {
+			delete(t.recorded, k)
+		}
+	}
+	t.recorded[key] = now
+	return true
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"testing"
+	"time"
+)
+
+func TestEventThrottle(t *testing.T) {
+	first := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "first", UID: "1"}}
+	second := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "second", UID: "2"}}
+	throttle := newEventThrottle(time.Minute)
+	tests := []struct {
+		name      string
+		object    runtime.Object
+		eventType string
+		reason    string
+		expire    bool
+		want      bool
+	}{
+		{name: "first event", object: first, eventType: v1.EventTypeWarning, reason: EventReasonReconcileFailed, want: true},
+		{name: "same reason", object: first, eventType: v1.EventTypeWarning, reason: EventReasonReconcileFailed, want: false},
+		{name: "other reason", object: first, eventType: v1.EventTypeWarning, reason: EventReasonCleanupFailed, want: true},
+		{name: "other type", object: first, eventType: v1.EventTypeNormal, reason: EventReasonReconcileFailed, want: true},
+		{name: "other object", object: second, eventType: v1.EventTypeWarning, reason: EventReasonReconcileFailed, want: true},
+		{name: "interval elapsed", object: first, eventType: v1.EventTypeWarning, reason: EventReasonReconcileFailed, expire: true, want: true},
+		{name: "throttled again", object: first, eventType: v1.EventTypeWarning, reason: EventReasonReconcileFailed, want: false},
+	}
+	for _, tt := range tests {
+		if tt.expire {
+			for key := range throttle.recorded {
+				throttle.recorded[key] = time.Now().Add(-throttle.interval)
+			}
+		}
+		if got := throttle.allow(tt.object, tt.eventType, tt.reason); got != tt.want {
+			t.Errorf("%s: expected allowed: %v, got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRecordEventThrottlesChangingMessages(t *testing.T) {
+	object := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test", UID: "1"}}
+	c := NewContext(&testManager{}).(*contextImpl)
+	recorder := c.Recorder().(*record.FakeRecorder)
+	for i := 0; i < 3; i++ {
+		c.WarningEvent(object, EventReasonReconcileFailed, "conflict on resourceVersion %d", i)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected one event, got: %d", len(recorder.Events))
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// addFinalizer adds the finalizer to the object if it's not already present
+func (c *contextImpl) addFinalizer(ctx context.Context, object client.Object, finalizer string) error {
+	return c.patchWithRetry(ctx, object, false, func(obj client.Object) bool {
+		return controllerutil.AddFinalizer(obj, finalizer)
+	})
+}
+
+// removeFinalizer removes the finalizer from the object if present
+func (c *contextImpl) removeFinalizer(ctx context.Context, object client.Object, finalizer string) error {
+	err := c.patchWithRetry(ctx, object, false, func(obj client.Object) bool {
+		return controllerutil.RemoveFinalizer(obj, finalizer)
+	})
+	if errors.IsNotFound(err) {
+		// the object is gone once its last finalizer is removed
+		return nil
+	}
+	return err
+}
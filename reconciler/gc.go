@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// GarbageCollection declares the objects of an owner to keep while collecting its orphaned objects
+type GarbageCollection struct {
+	// Owner is the reconciled object whose orphaned objects are collected
+	Owner metav1.Object
+	// Kinds are empty lists of the kinds to collect e.g. &v1.ServiceList{}
+	Kinds []client.ObjectList
+	// Namespaces restricts the search of the objects to the namespaces, e.g. those watched by the operator.
+	// Every namespace and the cluster-scoped objects are searched if empty, which needs the RBAC to list
+	// the kinds cluster-wide
+	Namespaces []string
+	// Desired are the objects desired by the current reconciliation which are kept
+	Desired []client.Object
+	// DryRun logs the orphaned objects without deleting them
+	DryRun bool
+}
+
+// ManagedLabels returns the labels identifying the objects of the owner managed by the operator.
+// The instance is the owner UID, unique across the namespaces and kinds unlike its name.
+// The objects collected by CollectGarbage must carry them
+func ManagedLabels(ctx Context, owner metav1.Object) map[string]string {
+	return map[string]string{
+		k8s.LabelAppManagedBy: ctx.FieldManager(),
+		k8s.LabelAppInstance:  string(owner.GetUID()),
+	}
+}
+
+// CollectGarbage deletes the objects carrying the ManagedLabels of the owner which are not desired anymore,
+// e.g. a removed client Service, and returns them. Unlike the owner reference garbage collection, this
+// catches the stale objects left behind when the spec of the owner shrinks, including those in other
+// namespaces and the cluster-scoped ones
+func CollectGarbage(ctx context.Context, rctx Context, gc GarbageCollection) ([]client.Object, error) {
+	if gc.Owner.GetUID() == "" {
+		return nil, fmt.Errorf("the owner %s has no UID to collect its objects by", gc.Owner.GetName())
+	}
+	desired := map[schema.GroupKind]map[types.NamespacedName]bool{}
+	for _, obj := range gc.Desired {
+		gvk, err := apiutil.GVKForObject(obj, rctx.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		if desired[gvk.GroupKind()] == nil {
+			desired[gvk.GroupKind()] = map[types.NamespacedName]bool{}
+		}
+		desired[gvk.GroupKind()][client.ObjectKeyFromObject(obj)] = true
+	}
+	namespaces := gc.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var orphans []client.Object
+	for _, kind := range gc.Kinds {
+		items, err := listManaged(ctx, rctx, kind, namespaces, ManagedLabels(rctx, gc.Owner))
+		if err != nil {
+			return orphans, err
+		}
+		for _, obj := range items {
+			gvk, err := apiutil.GVKForObject(obj, rctx.Scheme())
+			if err != nil {
+				return orphans, err
+			}
+			if desired[gvk.GroupKind()][client.ObjectKeyFromObject(obj)] {
+				continue
+			}
+			orphans = append(orphans, obj)
+			if gc.DryRun {
+				rctx.LoggerFrom(ctx).Info("[DryRun] Would delete the orphaned object",
+					"kind", gvk.Kind, "object", client.ObjectKeyFromObject(obj), "owner", gc.Owner.GetName())
+				continue
+			}
+			rctx.LoggerFrom(ctx).Info("Deleting the orphaned object",
+				"kind", gvk.Kind, "object", client.ObjectKeyFromObject(obj), "owner", gc.Owner.GetName())
+			if err = rctx.Client().Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				return orphans, err
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// listManaged lists the objects of the kind with the labels in the namespaces. A cluster-scoped
+// object listed for several namespaces is returned once
+func listManaged(ctx context.Context, rctx Context, kind client.ObjectList, namespaces []string, labels map[string]string) ([]client.Object, error) {
+	var objects []client.Object
+	listed := map[types.NamespacedName]bool{}
+	for _, namespace := range namespaces {
+		list := kind.DeepCopyObject().(client.ObjectList)
+		if err := rctx.Client().List(ctx, list, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok || listed[client.ObjectKeyFromObject(obj)] {
+				continue
+			}
+			listed[client.ObjectKeyFromObject(obj)] = true
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler_test
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/operator-helper/reconciler/reconcilertest"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"testing"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	owner := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app", UID: "uid-1"}}
+	// an owner of the same name in another namespace
+	other := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "app", UID: "uid-2"}}
+	rctx := reconcilertest.New(t, clientgoscheme.Scheme).Context()
+	service := func(namespace, name string, owner metav1.Object) *v1.Service {
+		svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		if owner != nil {
+			svc.Labels = reconciler.ManagedLabels(rctx, owner)
+		}
+		return svc
+	}
+	desired := service("test", "app-client", owner)
+	leftover := service("test", "app-admin", owner)
+	remote := service("remote", "app-client", owner)
+	othersChild := service("other", "app-admin", other)
+	unmanaged := service("test", "unmanaged", nil)
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "app-role", Labels: leftover.Labels}}
+	tests := []struct {
+		name       string
+		namespaces []string
+		dryRun     bool
+		orphans    []string
+	}{
+		{
+			name:    "leftovers deleted",
+			orphans: []string{"/app-role", "remote/app-client", "test/app-admin"},
+		},
+		{
+			name:    "dry run",
+			dryRun:  true,
+			orphans: []string{"/app-role", "remote/app-client", "test/app-admin"},
+		},
+		{
+			name:       "namespaces restricted",
+			namespaces: []string{"test", "other"},
+			orphans:    []string{"test/app-admin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := reconcilertest.New(t, clientgoscheme.Scheme, owner, other,
+				desired.DeepCopy(), leftover.DeepCopy(), remote.DeepCopy(), othersChild.DeepCopy(),
+				unmanaged.DeepCopy(), clusterRole.DeepCopy())
+			var orphans []client.Object
+			outcome := h.Reconcile(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+				var err error
+				orphans, err = reconciler.CollectGarbage(ctx, h.Context(), reconciler.GarbageCollection{
+					Owner:      owner,
+					Kinds:      []client.ObjectList{&v1.ServiceList{}, &rbacv1.ClusterRoleList{}},
+					Namespaces: tt.namespaces,
+					Desired:    []client.Object{desired.DeepCopy()},
+					DryRun:     tt.dryRun,
+				})
+				return reconcile.Result{}, err
+			}), types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}).AssertNoError()
+			var keys []string
+			for _, obj := range orphans {
+				keys = append(keys, client.ObjectKeyFromObject(obj).String())
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.orphans) {
+				t.Fatalf("expected the orphans %v, got: %v", tt.orphans, keys)
+			}
+			for _, obj := range orphans {
+				if tt.dryRun {
+					outcome.AssertNotWritten(obj)
+				} else {
+					outcome.AssertDeleted(obj)
+				}
+			}
+			for _, kept := range []client.Object{desired, othersChild, unmanaged} {
+				outcome.AssertNotWritten(kept)
+			}
+		})
+	}
+}
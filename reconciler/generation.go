@@ -0,0 +1,49 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObservedGenerationAware defines interface for the kubernetes object whose status
+// records the generation of the spec the operator last reconciled
+type ObservedGenerationAware interface {
+	KubeRuntimeObject
+
+	// GetObservedGeneration returns the observedGeneration of the object status
+	GetObservedGeneration() int64
+
+	// SetObservedGeneration sets the observedGeneration of the object status
+	SetObservedGeneration(generation int64)
+}
+
+// setObservedGeneration records the object generation as observed if the object is ObservedGenerationAware
+func (c *contextImpl) setObservedGeneration(ctx context.Context, object KubeRuntimeObject) error {
+	if _, ok := object.(ObservedGenerationAware); !ok {
+		return nil
+	}
+	return c.patchWithRetry(ctx, object, true, func(obj client.Object) bool {
+		og := obj.(ObservedGenerationAware)
+		if og.GetObservedGeneration() == og.GetGeneration() {
+			return false
+		}
+		og.SetObservedGeneration(og.GetGeneration())
+		return true
+	})
+}
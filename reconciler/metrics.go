@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	metricsNamespace = "operator_helper"
+	resultSuccess    = "success"
+	resultError      = "error"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconciliations run by Context.Run per kind",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 15),
+	}, []string{"kind"})
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Total number of the reconciliations run by Context.Run per kind and result",
+	}, []string{"kind", "result"})
+
+	reconcileDeletionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_deletions_total",
+		Help:      "Total number of the reconciliations of objects scheduled for deletion per kind",
+	}, []string{"kind"})
+
+	managedObjectsReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_objects_ready",
+		Help:      "Number of the managed objects with the Ready condition True per kind",
+	}, []string{"kind"})
+
+	managedObjectsPaused = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_objects_paused",
+		Help:      "Number of the managed objects whose reconciliation is paused per kind",
+	}, []string{"kind"})
+
+	driftCorrectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "drift_corrections_total",
+		Help:      "Total number of the owned objects applied back after drifting from their desired state per kind",
+	}, []string{"kind"})
+
+	readiness = newObjectTracker(managedObjectsReady)
+	pauses    = newObjectTracker(managedObjectsPaused)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileDuration,
+		reconcileTotal,
+		reconcileDeletionsTotal,
+		managedObjectsReady,
+		managedObjectsPaused,
+		driftCorrectionsTotal,
+	)
+}
+
+// kindOf returns the kind of the object to label the metrics with
+func kindOf(object runtime.Object, scheme *runtime.Scheme) string {
+	if gvk, err := apiutil.GVKForObject(object, scheme); err == nil {
+		return gvk.Kind
+	}
+	return fmt.Sprintf("%T", object)
+}
+
+func observeReconcile(kind string, duration time.Duration, err error) {
+	reconcileDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	reconcileTotal.WithLabelValues(kind, result).Inc()
+}
+
+func observeDeletion(kind string) {
+	reconcileDeletionsTotal.WithLabelValues(kind).Inc()
+}
+
+func observeDriftCorrection(kind string) {
+	driftCorrectionsTotal.WithLabelValues(kind).Inc()
+}
+
+// recordReadiness counts the object as ready if it's ConditionsAware with the Ready condition True
+func recordReadiness(kind string, key types.NamespacedName, object KubeRuntimeObject) {
+	ca, ok := object.(ConditionsAware)
+	readiness.set(kind, key, ok && IsConditionTrue(ca, ConditionReady))
+}
+
+// recordPause counts the object as paused or not
+func recordPause(kind string, key types.NamespacedName, paused bool) {
+	pauses.set(kind, key, paused)
+}
+
+// forgetObject stops counting the object once it's deleted
+func forgetObject(kind string, key types.NamespacedName) {
+	readiness.set(kind, key, false)
+	pauses.set(kind, key, false)
+}
+
+// objectTracker keeps the set of objects per kind matching a state to compute its gauge
+type objectTracker struct {
+	mu      sync.Mutex
+	gauge   *prometheus.GaugeVec
+	objects map[string]map[types.NamespacedName]bool
+}
+
+func newObjectTracker(gauge *prometheus.GaugeVec) *objectTracker {
+	return &objectTracker{
+		gauge:   gauge,
+		objects: map[string]map[types.NamespacedName]bool{},
+	}
+}
+
+func (t *objectTracker) set(kind string, key types.NamespacedName, in bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	objects, ok := t.objects[kind]
+	if !ok {
+		objects = map[types.NamespacedName]bool{}
+		t.objects[kind] = objects
+	}
+	if in {
+		objects[key] = true
+	} else {
+		delete(objects, key)
+	}
+	t.gauge.WithLabelValues(kind).Set(float64(len(objects)))
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/tools/record"
+	"time"
+)
+
+// ContextOption configures a Context created by NewContext
+type ContextOption func(*contextOptions)
+
+type contextOptions struct {
+	fieldManager   string
+	loggerName     string
+	recorder       record.EventRecorder
+	plan           *Plan
+	tracerProvider trace.TracerProvider
+}
+
+// WithFieldManager sets the field manager of the Context server-side applies
+// and the name of its default event recorder. Defaults to the operator name
+func WithFieldManager(name string) ContextOption {
+	return func(o *contextOptions) {
+		o.fieldManager = name
+	}
+}
+
+// WithLoggerName appends the name to the manager logger used by the Context
+func WithLoggerName(name string) ContextOption {
+	return func(o *contextOptions) {
+		o.loggerName = name
+	}
+}
+
+// WithRecorder sets the event recorder of the Context instead of one obtained from the manager
+func WithRecorder(recorder record.EventRecorder) ContextOption {
+	return func(o *contextOptions) {
+		o.recorder = recorder
+	}
+}
+
+// WithDryRun makes the Context send every write to the API server as a dry-run and record it in
+// the plan instead. Contexts created while the dry-run mode is enabled in config use the DefaultPlan
+func WithDryRun(plan *Plan) ContextOption {
+	return func(o *contextOptions) {
+		o.plan = plan
+	}
+}
+
+// WithTracerProvider makes the Context trace each Run with a span named after the kind of the request
+// object, with child spans for the pipeline steps and the API calls. Contexts created while tracing is
+// enabled in config use the global tracer provider; the others trace nothing
+func WithTracerProvider(provider trace.TracerProvider) ContextOption {
+	return func(o *contextOptions) {
+		o.tracerProvider = provider
+	}
+}
+
+// RunOption configures how Context.Run handles the request object
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	finalizer string
+	timeout   time.Duration
+}
+
+func newRunOptions(opts []RunOption) *runOptions {
+	options := &runOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithFinalizer makes Run add the named finalizer to the request object on its first
+// reconciliation and remove it only after the deletion callback returns without error.
+// A failing deletion callback is retried on the next reconciliation, so it must be idempotent
+func WithFinalizer(name string) RunOption {
+	return func(o *runOptions) {
+		o.finalizer = name
+	}
+}
+
+// WithTimeout bounds a single reconciliation to the duration; the context passed
+// to the reconcile function and every API call made by Run is cancelled afterwards
+func WithTimeout(timeout time.Duration) RunOption {
+	return func(o *runOptions) {
+		o.timeout = timeout
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// patchWithRetry patches the changes made by the mutate func to the object or its status subresource.
+// The patch carries the object resourceVersion so a concurrent edit fails with a conflict;
+// the object is then re-fetched and the mutate func applied again on the latest version
+func (c *contextImpl) patchWithRetry(ctx context.Context, object client.Object, status bool, mutate func(obj client.Object) bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		original := object.DeepCopyObject().(client.Object)
+		if !mutate(object) {
+			return nil
+		}
+		patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+		var err error
+		if status {
+			err = c.Client().Status().Patch(ctx, object, patch)
+		} else {
+			err = c.Client().Patch(ctx, object, patch)
+		}
+		if errors.IsConflict(err) {
+			if getErr := c.Client().Get(ctx, client.ObjectKeyFromObject(object), object); getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+}
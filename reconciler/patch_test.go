@@ -0,0 +1,163 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"testing"
+)
+
+// testManager provides a Context with the client
+type testManager struct {
+	manager.Manager
+	client client.Client
+}
+
+func (m *testManager) GetClient() client.Client {
+	return m.client
+}
+
+func (m *testManager) GetScheme() *runtime.Scheme {
+	return clientgoscheme.Scheme
+}
+
+func (m *testManager) GetLogger() logr.Logger {
+	return logr.Discard()
+}
+
+func (m *testManager) GetEventRecorderFor(string) record.EventRecorder {
+	return record.NewFakeRecorder(100)
+}
+
+func TestPatchWithRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		// edits is the number of patches preceded by a concurrent edit of the object
+		edits int
+		// edit is the concurrent edit of the object
+		edit         func(obj *v1.ConfigMap, n int)
+		wantConflict bool
+		wantPatches  int
+		wantMutates  int
+		wantAnnots   map[string]string
+	}{
+		{
+			name:        "no conflict",
+			wantPatches: 1,
+			wantMutates: 1,
+			wantAnnots:  map[string]string{"app": "test", "owner": "operator"},
+		},
+		{
+			name:  "concurrent edits",
+			edits: 2,
+			edit: func(obj *v1.ConfigMap, n int) {
+				obj.Annotations[fmt.Sprintf("edit-%d", n)] = "user"
+			},
+			wantPatches: 3,
+			wantMutates: 3,
+			wantAnnots:  map[string]string{"app": "test", "owner": "operator", "edit-1": "user", "edit-2": "user"},
+		},
+		{
+			name:  "change made concurrently",
+			edits: 1,
+			edit: func(obj *v1.ConfigMap, n int) {
+				obj.Annotations["owner"] = "operator"
+			},
+			wantPatches: 1,
+			wantMutates: 2,
+			wantAnnots:  map[string]string{"app": "test", "owner": "operator"},
+		},
+		{
+			name:  "conflicts exhausted",
+			edits: 100,
+			edit: func(obj *v1.ConfigMap, n int) {
+				obj.Annotations["edit"] = fmt.Sprint(n)
+			},
+			wantConflict: true,
+			wantPatches:  retry.DefaultRetry.Steps,
+			wantMutates:  retry.DefaultRetry.Steps,
+			wantAnnots:   map[string]string{"app": "test", "edit": fmt.Sprint(retry.DefaultRetry.Steps)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test", Name: "test", Annotations: map[string]string{"app": "test"},
+			}}
+			patches := 0
+			cl := fake.NewClientBuilder().WithObjects(cm).WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patches++
+					if patches <= tt.edits {
+						live := &v1.ConfigMap{}
+						if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+							return err
+						}
+						tt.edit(live, patches)
+						if err := c.Update(ctx, live); err != nil {
+							return err
+						}
+					}
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).Build()
+			c := NewContext(&testManager{client: cl}).(*contextImpl)
+			object := &v1.ConfigMap{}
+			if err := cl.Get(context.Background(), client.ObjectKeyFromObject(cm), object); err != nil {
+				t.Fatal(err)
+			}
+			mutates := 0
+			err := c.patchWithRetry(context.Background(), object, false, func(obj client.Object) bool {
+				mutates++
+				annotations := obj.GetAnnotations()
+				if annotations["owner"] == "operator" {
+					return false
+				}
+				annotations["owner"] = "operator"
+				obj.SetAnnotations(annotations)
+				return true
+			})
+			if errors.IsConflict(err) != tt.wantConflict || (err != nil && !tt.wantConflict) {
+				t.Fatalf("expected conflict: %v, got: %v", tt.wantConflict, err)
+			}
+			if patches != tt.wantPatches || mutates != tt.wantMutates {
+				t.Errorf("expected %d patches and %d mutates, got: %d and %d",
+					tt.wantPatches, tt.wantMutates, patches, mutates)
+			}
+			live := &v1.ConfigMap{}
+			if err = cl.Get(context.Background(), client.ObjectKeyFromObject(cm), live); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(live.Annotations) != fmt.Sprint(tt.wantAnnots) {
+				t.Errorf("expected the annotations %v, got: %v", tt.wantAnnots, live.Annotations)
+			}
+		})
+	}
+}
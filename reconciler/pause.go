@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// AnnotationPaused is the annotation which when set to "true" pauses the reconciliation of the
+// object, e.g. during a manual maintenance. The deletion of a paused object is still handled
+const AnnotationPaused = "operator-helper/paused"
+
+const (
+	// ConditionPaused indicates the reconciliation of the object is paused
+	ConditionPaused = "Paused"
+	// ReasonPausedByAnnotation is the reason of the Paused condition set while the object is paused
+	ReasonPausedByAnnotation = "PausedByAnnotation"
+	// ReasonResumed is the reason of the Paused condition set once the object is no longer paused
+	ReasonResumed = "Resumed"
+)
+
+// IsPaused checks if the object has the paused annotation set to "true"
+func IsPaused(object metav1.Object) bool {
+	return strings.EqualFold(strings.TrimSpace(object.GetAnnotations()[AnnotationPaused]), "true")
+}
+
+// setPausedCondition sets the Paused condition of ConditionsAware object if paused or previously paused
+func (c *contextImpl) setPausedCondition(ctx context.Context, object KubeRuntimeObject, paused bool) error {
+	ca, ok := object.(ConditionsAware)
+	if !ok {
+		return nil
+	}
+	if paused {
+		return c.SetConditions(ctx, ca, NewCondition(ConditionPaused, metav1.ConditionTrue,
+			ReasonPausedByAnnotation, "The reconciliation is paused by the annotation: "+AnnotationPaused))
+	}
+	if IsConditionTrue(ca, ConditionPaused) {
+		return c.SetConditions(ctx, ca, NewCondition(ConditionPaused, metav1.ConditionFalse, ReasonResumed, ""))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/tracing"
+	"time"
+)
+
+type stepAction int
+
+const (
+	stepContinue stepAction = iota
+	stepStop
+	stepRequeue
+)
+
+// StepResult tells the Pipeline whether to continue with the next step
+type StepResult struct {
+	action       stepAction
+	requeueAfter time.Duration
+}
+
+// StepContinue returns a StepResult that continues with the next step
+func StepContinue() StepResult {
+	return StepResult{action: stepContinue}
+}
+
+// StepStop returns a StepResult that skips the remaining steps and completes the reconciliation
+func StepStop() StepResult {
+	return StepResult{action: stepStop}
+}
+
+// StepRequeueAfter returns a StepResult that skips the remaining steps and requeues the request
+// after the duration, e.g. while waiting for a StatefulSet to be ready
+func StepRequeueAfter(duration time.Duration) StepResult {
+	return StepResult{action: stepRequeue, requeueAfter: duration}
+}
+
+// StepError is the error of the Pipeline step which failed
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %s failed: %s", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Step is a named step of a Pipeline
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) (StepResult, error)
+}
+
+// Pipeline runs the steps of a reconciliation in the order they are added, e.g. the ConfigMap,
+// the Services, the PodDisruptionBudget, the StatefulSet and then the readiness check.
+// Its Reconcile method is the reconcile function of Context.RunWithContext; a step error is
+// returned as a StepError so Run reports the failed step in the Ready condition and the event
+type Pipeline struct {
+	ctx           Context
+	steps         []Step
+	deletionSteps []Step
+}
+
+// NewPipeline creates a new Pipeline logging with the Context logger
+func NewPipeline(ctx Context) *Pipeline {
+	return &Pipeline{ctx: ctx}
+}
+
+// AddStep appends a step run when the request object is not being deleted
+func (p *Pipeline) AddStep(name string, run func(ctx context.Context) (StepResult, error)) *Pipeline {
+	p.steps = append(p.steps, Step{Name: name, Run: run})
+	return p
+}
+
+// AddDeletionStep appends a step run when the request object is being deleted
+func (p *Pipeline) AddDeletionStep(name string, run func(ctx context.Context) (StepResult, error)) *Pipeline {
+	p.deletionSteps = append(p.deletionSteps, Step{Name: name, Run: run})
+	return p
+}
+
+// Reconcile runs either the deletion steps or the other steps in order until one fails or doesn't continue
+func (p *Pipeline) Reconcile(ctx context.Context, deleted bool) (Result, error) {
+	steps := p.steps
+	if deleted {
+		steps = p.deletionSteps
+	}
+	for _, step := range steps {
+		startTime := time.Now()
+		stepCtx, span := tracing.StartChild(ctx, "Step "+step.Name)
+		result, err := step.Run(stepCtx)
+		tracing.End(span, err)
+		duration := time.Since(startTime).Seconds()
+		if err != nil {
+			p.ctx.LoggerFrom(ctx).Error(err, "[Step] Failed", "step", step.Name, "durationSec", duration)
+			return Done(), &StepError{Step: step.Name, Err: err}
+		}
+		p.ctx.LoggerFrom(ctx).Info("[Step] Completed", "step", step.Name, "durationSec", duration)
+		switch result.action {
+		case stepStop:
+			p.ctx.LoggerFrom(ctx).Info("[Step] Stopping the pipeline", "step", step.Name)
+			return Done(), nil
+		case stepRequeue:
+			p.ctx.LoggerFrom(ctx).Info("[Step] Requeueing the request", "step", step.Name,
+				"afterSec", result.requeueAfter.Seconds())
+			return RequeueAfter(result.requeueAfter), nil
+		case stepContinue:
+		}
+	}
+	return Done(), nil
+}
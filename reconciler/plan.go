@@ -0,0 +1,296 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/monimesl/operator-helper/config"
+	"github.com/monimesl/operator-helper/k8s/drift"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PlanEndpointPath is the path the DefaultPlan is served at by the metrics server
+const PlanEndpointPath = "/plan"
+
+// The verbs of a PlannedChange
+const (
+	PlanVerbCreate      = "create"
+	PlanVerbUpdate      = "update"
+	PlanVerbPatch       = "patch"
+	PlanVerbDelete      = "delete"
+	PlanVerbDeleteAllOf = "deleteAllOf"
+)
+
+// DefaultPlan collects the changes of the Contexts created while the dry-run mode is enabled in config
+var DefaultPlan = NewPlan()
+
+// PlannedChange is a write a Context in dry-run mode would have made
+type PlannedChange struct {
+	Verb        string    `json:"verb"`
+	Kind        string    `json:"kind"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	SubResource string    `json:"subResource,omitempty"`
+	Diff        string    `json:"diff,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+func (p PlannedChange) key() string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", p.Kind, p.Namespace, p.Name, p.SubResource, p.Verb)
+}
+
+// Plan collects the changes planned by the Contexts in dry-run mode. Since nothing is applied the same
+// changes are planned on every reconciliation, so only the latest one per object and verb is kept
+type Plan struct {
+	mu      sync.Mutex
+	changes map[string]PlannedChange
+}
+
+// NewPlan creates an empty Plan
+func NewPlan() *Plan {
+	return &Plan{changes: map[string]PlannedChange{}}
+}
+
+// Changes returns the planned changes sorted by kind, namespace and name
+func (p *Plan) Changes() []PlannedChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, 0, len(p.changes))
+	for key := range p.changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	changes := make([]PlannedChange, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, p.changes[key])
+	}
+	return changes
+}
+
+// Reset removes all the planned changes
+func (p *Plan) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.changes = map[string]PlannedChange{}
+}
+
+// ServeHTTP writes the planned changes as JSON
+func (p *Plan) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.Changes()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (p *Plan) record(change PlannedChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.changes[change.key()] = change
+}
+
+// forget removes the planned change, e.g. an update which turned out to change nothing
+func (p *Plan) forget(change PlannedChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.changes, change.key())
+}
+
+// RegisterPlanEndpoint serves the DefaultPlan at PlanEndpointPath on the metrics server
+// of the manager options when the dry-run mode is enabled in config
+func RegisterPlanEndpoint(options *ctrl.Options) {
+	if !config.DryRunEnabled() {
+		return
+	}
+	if options.Metrics.ExtraHandlers == nil {
+		options.Metrics.ExtraHandlers = map[string]http.Handler{}
+	}
+	options.Metrics.ExtraHandlers[PlanEndpointPath] = DefaultPlan
+}
+
+// dryRunClient sends every write as a dry-run and records it in the plan instead
+type dryRunClient struct {
+	client.Client
+	plan   *Plan
+	logger logr.Logger
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.planned(PlanVerbCreate, obj, "", nil)
+	return nil
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.planned(PlanVerbUpdate, obj, "", live)
+	return nil
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	verb := PlanVerbPatch
+	if live == nil {
+		// a server-side apply creating the object
+		verb = PlanVerbCreate
+	}
+	c.planned(verb, obj, "", live)
+	return nil
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.planned(PlanVerbDelete, obj, "", nil)
+	return nil
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.Client.DeleteAllOf(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	options := &client.DeleteAllOfOptions{}
+	options.ApplyOptions(opts)
+	change := PlannedChange{
+		Verb:      PlanVerbDeleteAllOf,
+		Kind:      c.kindOf(obj),
+		Namespace: options.Namespace,
+		Diff:      fmt.Sprintf("labels=%v fields=%v", options.LabelSelector, options.FieldSelector),
+		Time:      time.Now(),
+	}
+	c.logger.Info("Planned change", "verb", change.Verb, "kind", change.Kind,
+		"objectNamespace", change.Namespace, "diff", change.Diff)
+	c.plan.record(change)
+	return nil
+}
+
+func (c *dryRunClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *dryRunClient) SubResource(subResource string) client.SubResourceClient {
+	return &dryRunSubResourceClient{
+		SubResourceClient: c.Client.SubResource(subResource),
+		parent:            c,
+		subResource:       subResource,
+	}
+}
+
+// live gets a copy of the object as it is before the write or nil if it does not exist
+func (c *dryRunClient) live(ctx context.Context, obj client.Object) client.Object {
+	live, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil
+	}
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if !errors.IsNotFound(err) {
+			c.logger.Error(err, "Cannot get the live object to diff the planned change",
+				"kind", c.kindOf(obj), "object", client.ObjectKeyFromObject(obj))
+		}
+		return nil
+	}
+	return live
+}
+
+// planned records the write. The diff of an update is between the live object and the one
+// returned by the dry-run, and an update changing nothing is not planned
+func (c *dryRunClient) planned(verb string, obj client.Object, subResource string, live client.Object) {
+	change := PlannedChange{
+		Verb:        verb,
+		Kind:        c.kindOf(obj),
+		Namespace:   obj.GetNamespace(),
+		Name:        obj.GetName(),
+		SubResource: subResource,
+		Time:        time.Now(),
+	}
+	if live != nil {
+		report, err := drift.Compare(obj, live)
+		if err != nil {
+			c.logger.Error(err, "Cannot diff the planned change", "kind", change.Kind,
+				"object", client.ObjectKeyFromObject(obj))
+		} else if !report.NeedsUpdate() {
+			c.plan.forget(change)
+			return
+		}
+		change.Diff = report.Diff
+	}
+	c.logger.Info("Planned change", "verb", change.Verb, "kind", change.Kind, "object", client.ObjectKeyFromObject(obj),
+		"subResource", change.SubResource, "diff", change.Diff)
+	c.plan.record(change)
+}
+
+func (c *dryRunClient) kindOf(obj runtime.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return fmt.Sprintf("%T", obj)
+	}
+	return gvk.Kind
+}
+
+type dryRunSubResourceClient struct {
+	client.SubResourceClient
+	parent      *dryRunClient
+	subResource string
+}
+
+func (c *dryRunSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object,
+	opts ...client.SubResourceCreateOption) error {
+	err := c.SubResourceClient.Create(ctx, obj, subResource, append(opts, client.DryRunAll)...)
+	if err != nil {
+		return err
+	}
+	c.parent.planned(PlanVerbCreate, obj, c.subResource, nil)
+	return nil
+}
+
+func (c *dryRunSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	live := c.parent.live(ctx, obj)
+	if err := c.SubResourceClient.Update(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.parent.planned(PlanVerbUpdate, obj, c.subResource, live)
+	return nil
+}
+
+func (c *dryRunSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) error {
+	live := c.parent.live(ctx, obj)
+	if err := c.SubResourceClient.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.parent.planned(PlanVerbPatch, obj, c.subResource, live)
+	return nil
+}
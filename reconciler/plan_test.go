@@ -0,0 +1,231 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/operator-helper/reconciler/reconcilertest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+)
+
+// planReconciler creates, applies, updates and deletes the children of the ConfigMap
+type planReconciler struct {
+	ctx reconciler.Context
+}
+
+func (r *planReconciler) Configure(ctx reconciler.Context) error {
+	r.ctx = ctx
+	return nil
+}
+
+func (r *planReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	cm := &v1.ConfigMap{}
+	return r.ctx.RunWithContext(ctx, req, cm, func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+		if deleted {
+			return reconciler.Done(), nil
+		}
+		c := r.ctx.Client()
+		created := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cm.Namespace, Name: cm.Name + "-created"},
+			Data:       cm.Data,
+		}
+		if err := c.Create(ctx, created); err != nil {
+			return reconciler.Done(), err
+		}
+		applied := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cm.Namespace, Name: cm.Name + "-applied"},
+			Data:       map[string][]byte{"key": []byte(cm.Data["key"])},
+		}
+		if _, err := r.ctx.Apply(ctx, cm, applied); err != nil {
+			return reconciler.Done(), err
+		}
+		updated := &v1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: cm.Namespace, Name: cm.Name + "-updated"}, updated); err != nil {
+			return reconciler.Done(), err
+		}
+		updated.Data = cm.Data
+		if err := c.Update(ctx, updated); err != nil {
+			return reconciler.Done(), err
+		}
+		stale := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: cm.Namespace, Name: cm.Name + "-stale"}}
+		if err := c.Delete(ctx, stale); err != nil {
+			return reconciler.Done(), err
+		}
+		return reconciler.Done(), nil
+	})
+}
+
+func newPlanHarness(t *testing.T, plan *reconciler.Plan) (*reconcilertest.Harness, *planReconciler) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme,
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app", UID: "app-uid"},
+			Data:       map[string]string{"key": "new"},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-updated"},
+			Data:       map[string]string{"key": "old"},
+		},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-stale"}},
+	).WithDryRun(plan)
+	r := &planReconciler{}
+	if err := h.Configure(r); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	return h, r
+}
+
+func TestDryRunLeavesTheStoreUnchanged(t *testing.T) {
+	h, r := newPlanHarness(t, reconciler.NewPlan())
+	if !h.Context().DryRun() {
+		t.Fatal("expected the Context in dry-run mode")
+	}
+	key := types.NamespacedName{Namespace: "test", Name: "app"}
+	h.Reconcile(r, key).
+		AssertNoError().
+		AssertNoEvents().
+		AssertNotWritten(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-created"}}).
+		AssertNotWritten(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-applied"}}).
+		AssertNotWritten(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-updated"}}).
+		AssertNotWritten(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-stale"}})
+
+	ctx := context.Background()
+	c := h.Client()
+	for _, obj := range []client.Object{&v1.ConfigMap{}, &v1.Secret{}} {
+		name := "app-created"
+		if _, ok := obj.(*v1.Secret); ok {
+			name = "app-applied"
+		}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "test", Name: name}, obj); err == nil {
+			t.Errorf("expected %s not created", name)
+		}
+	}
+	updated := &v1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "app-updated"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Data["key"] != "old" {
+		t.Errorf("expected app-updated unchanged, got: %v", updated.Data)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "app-stale"}, &v1.Secret{}); err != nil {
+		t.Errorf("expected app-stale not deleted: %v", err)
+	}
+}
+
+func TestDryRunPlansTheChanges(t *testing.T) {
+	plan := reconciler.NewPlan()
+	h, r := newPlanHarness(t, plan)
+	key := types.NamespacedName{Namespace: "test", Name: "app"}
+	h.Reconcile(r, key).AssertNoError()
+
+	type planned struct {
+		verb, kind, name string
+	}
+	var got []planned
+	diffs := map[string]string{}
+	for _, change := range plan.Changes() {
+		if change.Namespace != "test" || change.Time.IsZero() {
+			t.Errorf("unexpected change: %+v", change)
+		}
+		got = append(got, planned{change.Verb, change.Kind, change.Name})
+		diffs[change.Name] = change.Diff
+	}
+	want := []planned{
+		{reconciler.PlanVerbCreate, "ConfigMap", "app-created"},
+		{reconciler.PlanVerbUpdate, "ConfigMap", "app-updated"},
+		{reconciler.PlanVerbCreate, "Secret", "app-applied"},
+		{reconciler.PlanVerbDelete, "Secret", "app-stale"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the changes %v, got %v", want, got)
+	}
+	if diff := diffs["app-updated"]; !strings.Contains(diff, "old") || !strings.Contains(diff, "new") {
+		t.Errorf("expected the diff of the update, got: %q", diff)
+	}
+	if diffs["app-created"] != "" || diffs["app-stale"] != "" {
+		t.Errorf("expected no diff for the create and delete, got: %v", diffs)
+	}
+
+	// the same changes are planned again, keeping the latest one per object and verb
+	h.Reconcile(r, key).AssertNoError()
+	if n := len(plan.Changes()); n != len(want) {
+		t.Errorf("expected %d changes after replanning, got %d", len(want), n)
+	}
+}
+
+func TestPlanEndpoint(t *testing.T) {
+	plan := reconciler.NewPlan()
+	h, r := newPlanHarness(t, plan)
+	h.Reconcile(r, types.NamespacedName{Namespace: "test", Name: "app"}).AssertNoError()
+
+	rec := httptest.NewRecorder()
+	plan.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, reconciler.PlanEndpointPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content, got %q", ct)
+	}
+	var served []reconciler.PlannedChange
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	changes := plan.Changes()
+	if len(served) != len(changes) {
+		t.Fatalf("expected %d served changes, got %d", len(changes), len(served))
+	}
+	for i := range changes {
+		if served[i].Verb != changes[i].Verb || served[i].Kind != changes[i].Kind ||
+			served[i].Name != changes[i].Name || served[i].Diff != changes[i].Diff {
+			t.Errorf("expected the served change %+v, got %+v", changes[i], served[i])
+		}
+	}
+
+	plan.Reset()
+	rec = httptest.NewRecorder()
+	plan.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, reconciler.PlanEndpointPath, nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("expected no changes after a reset, got %s", body)
+	}
+}
+
+func TestRegisterPlanEndpoint(t *testing.T) {
+	options := &ctrl.Options{}
+	reconciler.RegisterPlanEndpoint(options)
+	if _, ok := options.Metrics.ExtraHandlers[reconciler.PlanEndpointPath]; ok {
+		t.Fatal("expected no plan endpoint without the dry-run mode")
+	}
+	_ = os.Setenv("DRY_RUN", "true")
+	defer func() { _ = os.Unsetenv("DRY_RUN") }()
+	reconciler.RegisterPlanEndpoint(options)
+	if options.Metrics.ExtraHandlers[reconciler.PlanEndpointPath] != reconciler.DefaultPlan {
+		t.Fatal("expected the DefaultPlan served in dry-run mode")
+	}
+}
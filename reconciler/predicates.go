@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// GenerationChanged returns a predicate passing the updates that changed the object generation, i.e. its spec
+func GenerationChanged() predicate.Predicate {
+	return predicate.GenerationChangedPredicate{}
+}
+
+// AnnotationsChanged returns a predicate passing the updates that changed the object annotations
+func AnnotationsChanged() predicate.Predicate {
+	return predicate.AnnotationChangedPredicate{}
+}
+
+// LabelsChanged returns a predicate passing the updates that changed the object labels
+func LabelsChanged() predicate.Predicate {
+	return predicate.LabelChangedPredicate{}
+}
+
+// IgnoreStatusUpdates returns a predicate dropping the updates that changed nothing but the object status
+// and the metadata maintained by the server, e.g. the status writes of the operator itself. The updates of
+// objects without generation, such as ConfigMaps, always pass as their data changes can't be told apart
+func IgnoreStatusUpdates() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return true
+			}
+			oldObj, newObj := e.ObjectOld, e.ObjectNew
+			if newObj.GetGeneration() == 0 || oldObj.GetGeneration() != newObj.GetGeneration() {
+				return true
+			}
+			return !equality.Semantic.DeepEqual(oldObj.GetLabels(), newObj.GetLabels()) ||
+				!equality.Semantic.DeepEqual(oldObj.GetAnnotations(), newObj.GetAnnotations()) ||
+				!equality.Semantic.DeepEqual(oldObj.GetFinalizers(), newObj.GetFinalizers()) ||
+				!equality.Semantic.DeepEqual(oldObj.GetOwnerReferences(), newObj.GetOwnerReferences()) ||
+				!equality.Semantic.DeepEqual(oldObj.GetDeletionTimestamp(), newObj.GetDeletionTimestamp())
+		},
+	}
+}
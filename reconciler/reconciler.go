@@ -17,9 +17,11 @@
 package reconciler
 
 import (
+	"context"
 	"github.com/go-logr/logr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"log"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -27,9 +29,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// Configure let the added reconcilers to configure themselves
+// Configure let the added reconcilers to configure themselves with a new Context of the manager
 func Configure(manager ctrl.Manager, reconcilers ...Reconciler) error {
-	ctx := NewContext(manager)
+	return ConfigureWithContext(NewContext(manager), reconcilers...)
+}
+
+// ConfigureWithContext let the added reconcilers to configure themselves with the Context.
+// The Context is also kept as the one returned by the deprecated GetContext
+func ConfigureWithContext(ctx Context, reconcilers ...Reconciler) error {
+	instance = ctx
 	for _, r := range reconcilers {
 		log.Printf("configuring the reconciler: %T\n", r)
 		if err := r.Configure(ctx); err != nil {
@@ -72,21 +80,73 @@ type Context interface {
 	// NewControllerBuilder returns a new builder to create a controllers
 	NewControllerBuilder() *builder.Builder
 
-	// Client returns the underlying client
+	// NewWebhookBuilder returns a new builder to create a webhook
+	NewWebhookBuilder() *builder.WebhookBuilder
+
+	// FieldManager returns the field manager name used for server-side apply
+	FieldManager() string
+
+	// FieldIndexer returns the underlying field indexer
+	FieldIndexer() client.FieldIndexer
+
+	// Client returns the underlying client. In dry-run mode its writes are only planned
 	Client() client.Client
 
+	// DryRun checks if the Context only plans its writes; see WithDryRun
+	DryRun() bool
+
 	// Scheme returns the underlying scheme
 	Scheme() *runtime.Scheme
 
 	// Logger returns the underlying logger
 	Logger() logr.Logger
 
-	// Run checks if the reconciliation can be done and call the reconcile function to do so
-	Run(req reconcile.Request, runtimeObject KubeRuntimeObject, reconcile func(deleted bool) error) (reconcile.Result, error)
+	// LoggerFrom returns the per-reconcile logger RunWithContext puts in the ctx, which carries the
+	// request namespace, name and reconcile ID, or the underlying logger if there is none
+	LoggerFrom(ctx context.Context) logr.Logger
+
+	// Recorder returns the underlying event recorder
+	Recorder() record.EventRecorder
+
+	// NormalEvent records a Normal event on the object. An event of the same
+	// object, type and reason is recorded once within the throttle interval
+	NormalEvent(object runtime.Object, reason, messageFmt string, args ...interface{})
+
+	// WarningEvent records a Warning event on the object. An event of the same
+	// object, type and reason is recorded once within the throttle interval
+	WarningEvent(object runtime.Object, reason, messageFmt string, args ...interface{})
+
+	// Run checks if the reconciliation can be done and call the reconcile function to do so.
+	// A failing reconcile function is recorded as a Warning event on the object and, when
+	// the object is ConditionsAware, sets its Ready condition to False. The reconcile function is not called
+	// for an object paused with the AnnotationPaused annotation unless the object is being deleted.
+	// The generation of a successfully reconciled ObservedGenerationAware object is recorded in its status
+	Run(req reconcile.Request, runtimeObject KubeRuntimeObject, reconcile func(deleted bool) error, options ...RunOption) (reconcile.Result, error)
+
+	// RunWithResult is like Run but the reconcile function also returns a Result telling whether and when to
+	// requeue the request. A deletion that does not return Done keeps the finalizer until a later one does.
+	// Errors marked Terminal are reported without requeue while any other error is retried with backoff
+	RunWithResult(req reconcile.Request, runtimeObject KubeRuntimeObject, reconcile func(deleted bool) (Result, error), options ...RunOption) (reconcile.Result, error)
+
+	// RunWithContext is like RunWithResult but uses the context for every API call and passes it to the reconcile
+	// function, so the manager shutdown and the WithTimeout option cancel the reconciliation
+	RunWithContext(ctx context.Context, req reconcile.Request, runtimeObject KubeRuntimeObject, reconcile func(ctx context.Context, deleted bool) (Result, error), options ...RunOption) (reconcile.Result, error)
 
 	// SetOwnershipReference set ownership of the controlled object to the owner
 	SetOwnershipReference(owner metav1.Object, controlled metav1.Object) error
 
+	// SetConditions sets the conditions on the object status and patches the status subresource,
+	// retrying with the latest version of the object on conflict
+	SetConditions(ctx context.Context, object ConditionsAware, conditions ...metav1.Condition) error
+
+	// Apply sets the owner of the desired object and applies it with server-side apply using the
+	// operator field manager. The object is created if missing otherwise only the fields it sets
+	// are applied, keeping those set by other controllers. Returns true if the object was created or changed
+	Apply(ctx context.Context, owner metav1.Object, desired client.Object) (changed bool, err error)
+
 	// GetResource is a helper to method to get a resource and do something about its availability
 	GetResource(key client.ObjectKey, object client.Object, foundCallback func() (err error), notFoundCallback func() (err error)) error
+
+	// GetResourceWithContext is like GetResource but uses the context for the API call
+	GetResourceWithContext(ctx context.Context, key client.ObjectKey, object client.Object, foundCallback func() (err error), notFoundCallback func() (err error)) error
 }
@@ -0,0 +1,405 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconcilertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"hash/fnv"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelRevisionHash is the pod label holding the revision of the StatefulSet template it was created from
+const LabelRevisionHash = appsv1.ControllerRevisionHashLabelKey
+
+// LabelPodTemplateHash is the pod label holding the hash of the Deployment template it was created from
+const LabelPodTemplateHash = appsv1.DefaultDeploymentUniqueLabelKey
+
+// Cluster simulates the StatefulSet and Deployment controllers of a kube-controller-manager against a
+// fake client, which never moves the workloads status. Each Sync creates the missing pods, deletes those
+// beyond the desired replicas, rolls the pods of an updated template and updates the workloads status
+// from the pods. A StatefulSet replaces its outdated pods one at a time, from the highest ordinal, once the
+// others are ready. A Deployment creates the pods of the new template at once, named and labelled with
+// its LabelPodTemplateHash, and deletes an old pod for each new one getting ready. The Deployment pods
+// are controlled by the Deployment itself as no ReplicaSet is simulated. The pods readiness is set on demand
+type Cluster struct {
+	client client.Client
+	// AutoReady makes the created pods ready right away
+	AutoReady bool
+}
+
+// NewCluster creates a Cluster over the client
+func NewCluster(c client.Client) *Cluster {
+	return &Cluster{client: c}
+}
+
+// Cluster creates a Cluster over the Harness client
+func (h *Harness) Cluster() *Cluster {
+	return NewCluster(h.fakeClient())
+}
+
+// Sync runs a single pass of the simulated controllers over every StatefulSet and Deployment
+func (c *Cluster) Sync(ctx context.Context) error {
+	stsList := &appsv1.StatefulSetList{}
+	if err := c.client.List(ctx, stsList); err != nil {
+		return err
+	}
+	for i := range stsList.Items {
+		if err := c.syncStatefulSet(ctx, &stsList.Items[i]); err != nil {
+			return err
+		}
+	}
+	depList := &appsv1.DeploymentList{}
+	if err := c.client.List(ctx, depList); err != nil {
+		return err
+	}
+	for i := range depList.Items {
+		if err := c.syncDeployment(ctx, &depList.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPodReady marks the pod Running and Ready or not
+func (c *Cluster) SetPodReady(ctx context.Context, key types.NamespacedName, ready bool) error {
+	return c.updatePodStatus(ctx, key, func(p *v1.Pod) {
+		setPodReady(p, ready)
+	})
+}
+
+// SetPodFailed marks the pod Failed and not Ready
+func (c *Cluster) SetPodFailed(ctx context.Context, key types.NamespacedName) error {
+	return c.updatePodStatus(ctx, key, func(p *v1.Pod) {
+		setPodReady(p, false)
+		p.Status.Phase = v1.PodFailed
+	})
+}
+
+// SetAllPodsReady marks every pod of the namespace matching the labels Running and Ready or not
+func (c *Cluster) SetAllPodsReady(ctx context.Context, namespace string, labels map[string]string, ready bool) error {
+	pods, err := pod.ListAllWithMatchingLabelsWithContext(ctx, c.client, namespace, labels)
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if err = c.SetPodReady(ctx, client.ObjectKeyFromObject(&pods.Items[i]), ready); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) updatePodStatus(ctx context.Context, key types.NamespacedName, mutate func(p *v1.Pod)) error {
+	p := &v1.Pod{}
+	if err := c.client.Get(ctx, key, p); err != nil {
+		return err
+	}
+	mutate(p)
+	return c.client.Status().Update(ctx, p)
+}
+
+func (c *Cluster) syncStatefulSet(ctx context.Context, sts *appsv1.StatefulSet) error {
+	revision := fmt.Sprintf("%s-%s", sts.Name, templateHash(&sts.Spec.Template))
+	names := make([]string, replicasOf(sts.Spec.Replicas))
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", sts.Name, i)
+	}
+	pods, err := c.syncPods(ctx, sts, revision, names)
+	if err != nil {
+		return err
+	}
+	status := appsv1.StatefulSetStatus{
+		ObservedGeneration: sts.Generation,
+		UpdateRevision:     revision,
+		CurrentRevision:    revision,
+	}
+	for i := range pods {
+		status.Replicas++
+		if pod.IsReady(&pods[i]) {
+			status.ReadyReplicas++
+			status.AvailableReplicas++
+		}
+		if pods[i].Labels[LabelRevisionHash] == revision {
+			status.UpdatedReplicas++
+		} else {
+			status.CurrentRevision = pods[i].Labels[LabelRevisionHash]
+		}
+	}
+	if status.CurrentRevision == revision {
+		status.CurrentReplicas = status.UpdatedReplicas
+	} else {
+		status.CurrentReplicas = status.Replicas - status.UpdatedReplicas
+	}
+	sts.Status = status
+	return c.client.Status().Update(ctx, sts)
+}
+
+func (c *Cluster) syncDeployment(ctx context.Context, dep *appsv1.Deployment) error {
+	hash := templateHash(&dep.Spec.Template)
+	names := make([]string, replicasOf(dep.Spec.Replicas))
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%s-%d", dep.Name, hash, i)
+	}
+	owned, err := c.ownedPods(ctx, dep, dep.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	desired := map[string]bool{}
+	for _, name := range names {
+		desired[name] = true
+	}
+	current := map[string]*v1.Pod{}
+	var old []*v1.Pod
+	for _, p := range owned {
+		switch {
+		case p.Labels[LabelPodTemplateHash] != hash:
+			old = append(old, p)
+		case desired[p.Name]:
+			current[p.Name] = p
+		default:
+			// scaled down
+			if err = c.deletePod(ctx, p); err != nil {
+				return err
+			}
+		}
+	}
+	pods := make([]v1.Pod, 0, len(names)+len(old))
+	ready := 0
+	for _, name := range names {
+		p, ok := current[name]
+		if !ok {
+			if p, err = c.createPod(ctx, dep, &dep.Spec.Template, name, LabelPodTemplateHash, hash); err != nil {
+				return err
+			}
+		}
+		if pod.IsReady(p) {
+			ready++
+		}
+		pods = append(pods, *p)
+	}
+	// an old pod is deleted for each new one ready
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].Name < old[j].Name
+	})
+	keep := len(names) - ready
+	for i, p := range old {
+		if i >= keep {
+			if err = c.deletePod(ctx, p); err != nil {
+				return err
+			}
+			continue
+		}
+		pods = append(pods, *p)
+	}
+	status := appsv1.DeploymentStatus{ObservedGeneration: dep.Generation}
+	for i := range pods {
+		status.Replicas++
+		if pod.IsReady(&pods[i]) {
+			status.ReadyReplicas++
+			status.AvailableReplicas++
+		} else {
+			status.UnavailableReplicas++
+		}
+		if pods[i].Labels[LabelPodTemplateHash] == hash {
+			status.UpdatedReplicas++
+		}
+	}
+	dep.Status = status
+	return c.client.Status().Update(ctx, dep)
+}
+
+// ownedPods lists the pods of the owner namespace matching the selector and controlled by the owner
+func (c *Cluster) ownedPods(ctx context.Context, owner client.Object, selector *metav1.LabelSelector) ([]*v1.Pod, error) {
+	var labels map[string]string
+	if selector != nil {
+		labels = selector.MatchLabels
+	}
+	list, err := pod.ListAllWithMatchingLabelsWithContext(ctx, c.client, owner.GetNamespace(), labels)
+	if err != nil {
+		return nil, err
+	}
+	var pods []*v1.Pod
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], owner) {
+			pods = append(pods, &list.Items[i])
+		}
+	}
+	return pods, nil
+}
+
+func (c *Cluster) deletePod(ctx context.Context, p *v1.Pod) error {
+	if err := c.client.Delete(ctx, p); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// syncPods reconciles the pods of the StatefulSet to the desired names and returns them
+func (c *Cluster) syncPods(ctx context.Context, sts *appsv1.StatefulSet, revision string, names []string) ([]v1.Pod, error) {
+	owned, err := c.ownedPods(ctx, sts, sts.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	desired := map[string]bool{}
+	for _, name := range names {
+		desired[name] = true
+	}
+	existing := map[string]*v1.Pod{}
+	for _, p := range owned {
+		if !desired[p.Name] {
+			// scaled down
+			if err = c.deletePod(ctx, p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		existing[p.Name] = p
+	}
+	if err = c.rollOne(ctx, names, existing, revision); err != nil {
+		return nil, err
+	}
+	pods := make([]v1.Pod, 0, len(names))
+	for _, name := range names {
+		p, ok := existing[name]
+		if !ok {
+			if p, err = c.createPod(ctx, sts, &sts.Spec.Template, name, LabelRevisionHash, revision); err != nil {
+				return nil, err
+			}
+		}
+		pods = append(pods, *p)
+	}
+	return pods, nil
+}
+
+// rollOne deletes the outdated pod with the highest ordinal when all the other pods are ready
+func (c *Cluster) rollOne(ctx context.Context, names []string, existing map[string]*v1.Pod, revision string) error {
+	var outdated []string
+	for _, name := range names {
+		p, ok := existing[name]
+		if !ok {
+			return nil
+		}
+		if p.Labels[LabelRevisionHash] != revision {
+			outdated = append(outdated, name)
+		} else if !pod.IsReady(p) {
+			return nil
+		}
+	}
+	if len(outdated) == 0 {
+		return nil
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		return ordinal(outdated[i]) > ordinal(outdated[j])
+	})
+	for _, name := range outdated[1:] {
+		if !pod.IsReady(existing[name]) {
+			return nil
+		}
+	}
+	if err := c.deletePod(ctx, existing[outdated[0]]); err != nil {
+		return err
+	}
+	delete(existing, outdated[0])
+	return nil
+}
+
+func (c *Cluster) createPod(ctx context.Context, owner client.Object, template *v1.PodTemplateSpec,
+	name, revisionLabel, revision string) (*v1.Pod, error) {
+	p := &v1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	p.Name = name
+	p.GenerateName = ""
+	p.Namespace = owner.GetNamespace()
+	if p.Labels == nil {
+		p.Labels = map[string]string{}
+	}
+	p.Labels[revisionLabel] = revision
+	controller := true
+	gvk := owner.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvk = appsv1.SchemeGroupVersion.WithKind(kindOfWorkload(owner))
+	}
+	p.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+		Controller: &controller,
+	}}
+	if err := c.client.Create(ctx, p); err != nil {
+		return nil, err
+	}
+	setPodReady(p, c.AutoReady)
+	if err := c.client.Status().Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func setPodReady(p *v1.Pod, ready bool) {
+	p.Status.Phase = v1.PodRunning
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	for i := range p.Status.Conditions {
+		if p.Status.Conditions[i].Type == v1.PodReady {
+			p.Status.Conditions[i].Status = status
+			return
+		}
+	}
+	p.Status.Conditions = append(p.Status.Conditions, v1.PodCondition{Type: v1.PodReady, Status: status})
+}
+
+func kindOfWorkload(owner client.Object) string {
+	if _, ok := owner.(*appsv1.Deployment); ok {
+		return "Deployment"
+	}
+	return "StatefulSet"
+}
+
+func replicasOf(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
+
+func ordinal(name string) int {
+	i, _ := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	return i
+}
+
+func templateHash(template *v1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return strconv.FormatUint(uint64(hasher.Sum32()), 16)
+}
@@ -0,0 +1,373 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconcilertest_test
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/deployment"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/k8s/statefulset"
+	"github.com/monimesl/operator-helper/reconciler/reconcilertest"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"testing"
+)
+
+var workloadLabels = map[string]string{"app": "web"}
+
+func newWorkloadTemplate(image string) v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: workloadLabels},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: image}}},
+	}
+}
+
+func newStatefulSet(replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web", UID: "sts-uid"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: workloadLabels},
+			Template: newWorkloadTemplate("web:1"),
+		},
+	}
+}
+
+func newDeployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web", UID: "dep-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: workloadLabels},
+			Template: newWorkloadTemplate("web:1"),
+		},
+	}
+}
+
+// syncCluster runs the simulated controllers and returns the sorted pods of the workload labels
+func syncCluster(t *testing.T, c client.Client, cluster *reconcilertest.Cluster) []v1.Pod {
+	t.Helper()
+	if err := cluster.Sync(context.TODO()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	pods, err := pod.ListAllWithMatchingLabels(c, "ns", workloadLabels)
+	if err != nil {
+		t.Fatalf("list pods: %v", err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	return pods.Items
+}
+
+func podNames(pods []v1.Pod) []string {
+	names := make([]string, len(pods))
+	for i := range pods {
+		names[i] = pods[i].Name
+	}
+	return names
+}
+
+func assertPodNames(t *testing.T, pods []v1.Pod, want ...string) {
+	t.Helper()
+	got := podNames(pods)
+	if len(got) != len(want) {
+		t.Fatalf("expected the pods %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected the pods %v, got %v", want, got)
+		}
+	}
+}
+
+func updateImage(t *testing.T, c client.Client, obj client.Object, image string) {
+	t.Helper()
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	switch o := obj.(type) {
+	case *appsv1.StatefulSet:
+		o.Spec.Template.Spec.Containers[0].Image = image
+	case *appsv1.Deployment:
+		o.Spec.Template.Spec.Containers[0].Image = image
+	}
+	if err := c.Update(context.TODO(), obj); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+}
+
+func scale(t *testing.T, c client.Client, obj client.Object, replicas int32) {
+	t.Helper()
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	switch o := obj.(type) {
+	case *appsv1.StatefulSet:
+		o.Spec.Replicas = &replicas
+	case *appsv1.Deployment:
+		o.Spec.Replicas = &replicas
+	}
+	if err := c.Update(context.TODO(), obj); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+}
+
+func TestClusterStatefulSetScale(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newStatefulSet(3))
+	cluster := h.Cluster()
+	c := h.Client()
+	pods := syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-0", "web-1", "web-2")
+	for i := range pods {
+		if pods[i].Labels[reconcilertest.LabelRevisionHash] == "" {
+			t.Errorf("expected the pod %s to have the revision label", pods[i].Name)
+		}
+		if !metav1.IsControlledBy(&pods[i], &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{UID: "sts-uid"}}) {
+			t.Errorf("expected the pod %s to be controlled by the StatefulSet", pods[i].Name)
+		}
+	}
+	if statefulset.IsReady(c, "ns", "web", 3) {
+		t.Fatal("expected the StatefulSet not ready before its pods are")
+	}
+	if err := cluster.SetAllPodsReady(context.TODO(), "ns", workloadLabels, true); err != nil {
+		t.Fatal(err)
+	}
+	syncCluster(t, c, cluster)
+	if !statefulset.IsReady(c, "ns", "web", 3) {
+		t.Fatal("expected the StatefulSet ready")
+	}
+	scale(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, 5)
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-0", "web-1", "web-2", "web-3", "web-4")
+	if statefulset.IsReady(c, "ns", "web", 5) {
+		t.Fatal("expected the scaled up StatefulSet not ready before its new pods are")
+	}
+	scale(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, 2)
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-0", "web-1")
+	if !statefulset.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the scaled down StatefulSet ready")
+	}
+}
+
+func TestClusterStatefulSetRollingUpdate(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newStatefulSet(3))
+	cluster := h.Cluster()
+	cluster.AutoReady = true
+	c := h.Client()
+	pods := syncCluster(t, c, cluster)
+	oldRevision := pods[0].Labels[reconcilertest.LabelRevisionHash]
+	updateImage(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, "web:2")
+	// each pass replaces the outdated pod of the highest ordinal
+	for _, updated := range []string{"web-2", "web-1", "web-0"} {
+		pods = syncCluster(t, c, cluster)
+		for i := range pods {
+			if pods[i].Name == updated && pods[i].Labels[reconcilertest.LabelRevisionHash] == oldRevision {
+				t.Fatalf("expected the pod %s to be updated", updated)
+			}
+		}
+	}
+	for i := range pods {
+		if pods[i].Labels[reconcilertest.LabelRevisionHash] == oldRevision {
+			t.Errorf("expected the pod %s to be updated", pods[i].Name)
+		}
+	}
+	sts := &appsv1.StatefulSet{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web"}, sts); err != nil {
+		t.Fatal(err)
+	}
+	if sts.Status.UpdatedReplicas != 3 || sts.Status.CurrentRevision != sts.Status.UpdateRevision {
+		t.Errorf("expected the rollout complete, got the status %+v", sts.Status)
+	}
+	if !statefulset.IsReady(c, "ns", "web", 3) {
+		t.Fatal("expected the updated StatefulSet ready")
+	}
+}
+
+func TestClusterStatefulSetRollingUpdateWaitsForReadiness(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newStatefulSet(2))
+	cluster := h.Cluster()
+	c := h.Client()
+	syncCluster(t, c, cluster)
+	if err := cluster.SetAllPodsReady(context.TODO(), "ns", workloadLabels, true); err != nil {
+		t.Fatal(err)
+	}
+	updateImage(t, c, &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, "web:2")
+	syncCluster(t, c, cluster)
+	syncCluster(t, c, cluster)
+	// web-1 is replaced but not ready so web-0 is kept
+	pods := syncCluster(t, c, cluster)
+	if pods[0].Labels[reconcilertest.LabelRevisionHash] == pods[1].Labels[reconcilertest.LabelRevisionHash] {
+		t.Fatalf("expected the rollout to wait for web-1 to be ready")
+	}
+	if err := cluster.SetPodReady(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web-1"}, true); err != nil {
+		t.Fatal(err)
+	}
+	syncCluster(t, c, cluster)
+	pods = syncCluster(t, c, cluster)
+	if pods[0].Labels[reconcilertest.LabelRevisionHash] != pods[1].Labels[reconcilertest.LabelRevisionHash] {
+		t.Fatalf("expected web-0 to be updated once web-1 is ready")
+	}
+}
+
+func TestClusterSetPodReadyAndFailed(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newStatefulSet(2))
+	cluster := h.Cluster()
+	cluster.AutoReady = true
+	c := h.Client()
+	syncCluster(t, c, cluster)
+	if !statefulset.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the StatefulSet of auto ready pods ready")
+	}
+	key := types.NamespacedName{Namespace: "ns", Name: "web-1"}
+	if err := cluster.SetPodFailed(context.TODO(), key); err != nil {
+		t.Fatal(err)
+	}
+	p := &v1.Pod{}
+	if err := c.Get(context.TODO(), key, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status.Phase != v1.PodFailed || pod.IsReady(p) {
+		t.Fatalf("expected the pod failed and not ready, got %+v", p.Status)
+	}
+	syncCluster(t, c, cluster)
+	if statefulset.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the StatefulSet of a failed pod not ready")
+	}
+	if err := cluster.SetPodReady(context.TODO(), key, false); err != nil {
+		t.Fatal(err)
+	}
+	syncCluster(t, c, cluster)
+	if statefulset.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the StatefulSet of a not ready pod not ready")
+	}
+	if err := cluster.SetPodReady(context.TODO(), key, true); err != nil {
+		t.Fatal(err)
+	}
+	syncCluster(t, c, cluster)
+	if !statefulset.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the StatefulSet ready again")
+	}
+	if err := cluster.SetAllPodsReady(context.TODO(), "ns", workloadLabels, false); err != nil {
+		t.Fatal(err)
+	}
+	pods := syncCluster(t, c, cluster)
+	for i := range pods {
+		if pod.IsReady(&pods[i]) {
+			t.Errorf("expected the pod %s not ready", pods[i].Name)
+		}
+	}
+}
+
+func TestClusterDeploymentScale(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newDeployment(2))
+	cluster := h.Cluster()
+	c := h.Client()
+	pods := syncCluster(t, c, cluster)
+	if len(pods) != 2 {
+		t.Fatalf("expected 2 pods, got %v", podNames(pods))
+	}
+	hash := pods[0].Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if hash == "" {
+		t.Fatal("expected the pods to have the pod-template-hash label")
+	}
+	assertPodNames(t, pods, "web-"+hash+"-0", "web-"+hash+"-1")
+	if _, ok := pods[0].Labels[appsv1.ControllerRevisionHashLabelKey]; ok {
+		t.Error("expected the Deployment pods without the controller-revision-hash label")
+	}
+	if deployment.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the Deployment not ready before its pods are")
+	}
+	if err := cluster.SetAllPodsReady(context.TODO(), "ns", workloadLabels, true); err != nil {
+		t.Fatal(err)
+	}
+	syncCluster(t, c, cluster)
+	if !deployment.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the Deployment ready")
+	}
+	scale(t, c, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, 3)
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-"+hash+"-0", "web-"+hash+"-1", "web-"+hash+"-2")
+	if deployment.IsReady(c, "ns", "web", 3) {
+		t.Fatal("expected the scaled up Deployment not ready before its new pod is")
+	}
+	scale(t, c, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, 1)
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-"+hash+"-0")
+	if !deployment.IsReady(c, "ns", "web", 1) {
+		t.Fatal("expected the scaled down Deployment ready")
+	}
+}
+
+func TestClusterDeploymentRollingUpdate(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newDeployment(2))
+	cluster := h.Cluster()
+	c := h.Client()
+	pods := syncCluster(t, c, cluster)
+	oldHash := pods[0].Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if err := cluster.SetAllPodsReady(context.TODO(), "ns", workloadLabels, true); err != nil {
+		t.Fatal(err)
+	}
+	updateImage(t, c, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"}}, "web:2")
+	// the new pods are created next to the old ones until they are ready
+	pods = syncCluster(t, c, cluster)
+	if len(pods) != 4 {
+		t.Fatalf("expected the old and new pods, got %v", podNames(pods))
+	}
+	newHash := ""
+	for i := range pods {
+		if h := pods[i].Labels[appsv1.DefaultDeploymentUniqueLabelKey]; h != oldHash {
+			newHash = h
+		}
+	}
+	if newHash == "" {
+		t.Fatal("expected new pods of the updated template hash")
+	}
+	dep := &appsv1.Deployment{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web"}, dep); err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status.Replicas != 4 || dep.Status.UpdatedReplicas != 2 || dep.Status.UnavailableReplicas != 2 {
+		t.Fatalf("expected the Deployment rolling, got the status %+v", dep.Status)
+	}
+	// an old pod is deleted for each new one ready, from the last name
+	if err := cluster.SetPodReady(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web-" + newHash + "-0"}, true); err != nil {
+		t.Fatal(err)
+	}
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-"+newHash+"-0", "web-"+newHash+"-1", "web-"+oldHash+"-0")
+	if err := cluster.SetPodReady(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web-" + newHash + "-1"}, true); err != nil {
+		t.Fatal(err)
+	}
+	pods = syncCluster(t, c, cluster)
+	assertPodNames(t, pods, "web-"+newHash+"-0", "web-"+newHash+"-1")
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "web"}, dep); err != nil {
+		t.Fatal(err)
+	}
+	if dep.Status.UpdatedReplicas != 2 || dep.Status.UnavailableReplicas != 0 {
+		t.Errorf("expected the rollout complete, got the status %+v", dep.Status)
+	}
+	if !deployment.IsReady(c, "ns", "web", 2) {
+		t.Fatal("expected the updated Deployment ready")
+	}
+}
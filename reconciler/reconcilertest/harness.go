@@ -0,0 +1,411 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package reconcilertest provides a harness to unit test a reconciler.Reconciler
+// against a fake client without a cluster
+package reconcilertest
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr/testr"
+	"github.com/monimesl/operator-helper/reconciler"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sync"
+	"testing"
+)
+
+// Verb is the kind of write recorded by the Harness
+type Verb string
+
+const (
+	// VerbCreate is the creation of an object
+	VerbCreate Verb = "create"
+	// VerbUpdate is the update of an object or its subresource
+	VerbUpdate Verb = "update"
+	// VerbPatch is the patch of an object or its subresource, including server-side apply
+	VerbPatch Verb = "patch"
+	// VerbDelete is the deletion of an object
+	VerbDelete Verb = "delete"
+)
+
+// Action is a successful write made through the Harness client
+type Action struct {
+	Verb Verb
+	// Kind is the kind of the written object
+	Kind string
+	// Key is the namespaced name of the written object
+	Key types.NamespacedName
+	// SubResource is the written subresource e.g. status or empty for the object itself
+	SubResource string
+	// Object is a copy of the object as written
+	Object client.Object
+}
+
+// Harness drives a Reconciler against a fake client seeded with objects, recording the
+// writes and events of each reconciliation
+type Harness struct {
+	t        testing.TB
+	scheme   *runtime.Scheme
+	recorder *Recorder
+	manager  *fakeManager
+	ctx      reconciler.Context
+	mu       sync.Mutex
+	actions  []Action
+
+	buildMu sync.Mutex
+	builder *fake.ClientBuilder
+	indexes map[string]bool
+	client  client.WithWatch
+}
+
+// New creates a Harness with a fake client of the scheme seeded with the objects. The
+// status subresource is enabled for the seeded object types not built in Kubernetes.
+// The client is built on its first use, with the field indexes registered until then
+// e.g. by reconciler.SetupWatches, so the reconcilers must be configured first
+func New(t testing.TB, scheme *runtime.Scheme, objects ...client.Object) *Harness {
+	h := &Harness{t: t, scheme: scheme, recorder: NewRecorder(), indexes: map[string]bool{}}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	h.builder = fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(restMapper).
+		WithObjects(objects...).
+		WithInterceptorFuncs(h.interceptorFuncs())
+	for _, obj := range objects {
+		if gvk, err := apiutil.GVKForObject(obj, scheme); err == nil && !clientgoscheme.Scheme.Recognizes(gvk) {
+			h.builder = h.builder.WithStatusSubresource(obj)
+		}
+	}
+	logger := testr.NewWithInterface(t, testr.Options{})
+	h.manager = newFakeManager(h, restMapper, logger)
+	h.ctx = reconciler.NewContext(h.manager, reconciler.WithRecorder(h.recorder))
+	return h
+}
+
+// WithDryRun makes the Context of the Harness record its writes in the plan instead of applying
+// them; see reconciler.WithDryRun. The dry-run writes are not recorded as actions of the
+// reconciliations. It must be called before the reconcilers are configured
+func (h *Harness) WithDryRun(plan *reconciler.Plan) *Harness {
+	h.ctx = reconciler.NewContext(h.manager, reconciler.WithRecorder(h.recorder), reconciler.WithDryRun(plan))
+	return h
+}
+
+// fakeClient returns the fake client, building it on the first call
+func (h *Harness) fakeClient() client.WithWatch {
+	h.buildMu.Lock()
+	defer h.buildMu.Unlock()
+	if h.client == nil {
+		h.client = h.builder.Build()
+	}
+	return h.client
+}
+
+// indexField registers the field index on the fake client to build
+func (h *Harness) indexField(obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, h.scheme)
+	if err != nil {
+		return err
+	}
+	h.buildMu.Lock()
+	defer h.buildMu.Unlock()
+	if h.client != nil {
+		return fmt.Errorf("the index %s of %s must be registered before the client is used; configure the reconcilers first",
+			field, gvk.Kind)
+	}
+	key := gvk.String() + "/" + field
+	if h.indexes[key] {
+		return fmt.Errorf("indexer conflict: the index %s of %s is already registered", field, gvk.Kind)
+	}
+	h.indexes[key] = true
+	h.builder = h.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// Context returns the reconciler Context over the fake client to configure the Reconciler with
+func (h *Harness) Context() reconciler.Context {
+	return h.ctx
+}
+
+// Configure configures the Reconciler with the Context. Its controller is built but never
+// started so the watches do not trigger reconciliations; call Reconcile instead. The field
+// indexes it registers are available to the client if configured before the client is used
+func (h *Harness) Configure(r reconciler.Reconciler) error {
+	return r.Configure(h.ctx)
+}
+
+// Client returns the fake client
+func (h *Harness) Client() client.Client {
+	return h.fakeClient()
+}
+
+// Recorder returns the event recorder of the Context
+func (h *Harness) Recorder() *Recorder {
+	return h.recorder
+}
+
+// Reconcile calls the Reconciler for the request of the key and returns its Outcome
+func (h *Harness) Reconcile(r reconcile.Reconciler, key types.NamespacedName) *Outcome {
+	h.mu.Lock()
+	h.actions = nil
+	h.mu.Unlock()
+	h.recorder.Reset()
+	result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return &Outcome{
+		h:       h,
+		Result:  result,
+		Err:     err,
+		Actions: h.actions,
+		Events:  h.recorder.Events(),
+	}
+}
+
+func (h *Harness) record(verb Verb, subResource string, obj client.Object, dryRun []string) {
+	if len(dryRun) > 0 {
+		return
+	}
+	kind := fmt.Sprintf("%T", obj)
+	if gvk, err := apiutil.GVKForObject(obj, h.scheme); err == nil {
+		kind = gvk.Kind
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.actions = append(h.actions, Action{
+		Verb:        verb,
+		Kind:        kind,
+		Key:         client.ObjectKeyFromObject(obj),
+		SubResource: subResource,
+		Object:      obj.DeepCopyObject().(client.Object),
+	})
+}
+
+func (h *Harness) interceptorFuncs() interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			err := c.Create(ctx, obj, opts...)
+			if err == nil {
+				h.record(VerbCreate, "", obj, (&client.CreateOptions{}).ApplyOptions(opts).DryRun)
+			}
+			return err
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			err := c.Update(ctx, obj, opts...)
+			if err == nil {
+				h.record(VerbUpdate, "", obj, (&client.UpdateOptions{}).ApplyOptions(opts).DryRun)
+			}
+			return err
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			err := c.Patch(ctx, obj, patch, opts...)
+			dryRun := (&client.PatchOptions{}).ApplyOptions(opts).DryRun
+			if errors.IsNotFound(err) && patch.Type() == types.ApplyPatchType {
+				// the fake client does not create objects on server-side apply as the API server does
+				if err = c.Create(ctx, obj, applyCreateOptions(opts)...); err == nil {
+					h.record(VerbCreate, "", obj, dryRun)
+				}
+				return err
+			}
+			if err == nil {
+				h.record(VerbPatch, "", obj, dryRun)
+			}
+			return err
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			err := c.Delete(ctx, obj, opts...)
+			if err == nil {
+				h.record(VerbDelete, "", obj, (&client.DeleteOptions{}).ApplyOptions(opts).DryRun)
+			}
+			return err
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			err := c.SubResource(subResource).Update(ctx, obj, opts...)
+			if err == nil {
+				h.record(VerbUpdate, subResource, obj, (&client.SubResourceUpdateOptions{}).ApplyOptions(opts).DryRun)
+			}
+			return err
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			err := c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+			if err == nil {
+				h.record(VerbPatch, subResource, obj, (&client.SubResourcePatchOptions{}).ApplyOptions(opts).DryRun)
+			}
+			return err
+		},
+	}
+}
+
+// applyCreateOptions converts the options of a server-side apply to those of the equivalent create
+func applyCreateOptions(opts []client.PatchOption) []client.CreateOption {
+	patchOpts := &client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	var createOpts []client.CreateOption
+	if patchOpts.FieldManager != "" {
+		createOpts = append(createOpts, client.FieldOwner(patchOpts.FieldManager))
+	}
+	if len(patchOpts.DryRun) > 0 {
+		createOpts = append(createOpts, client.DryRunAll)
+	}
+	return createOpts
+}
+
+// Outcome is the result, the writes and the events of a reconciliation run by the Harness
+type Outcome struct {
+	h       *Harness
+	Result  reconcile.Result
+	Err     error
+	Actions []Action
+	Events  []Event
+}
+
+// AssertNoError fails the test if the reconciliation returned an error
+func (o *Outcome) AssertNoError() *Outcome {
+	o.h.t.Helper()
+	if o.Err != nil {
+		o.h.t.Fatalf("expected no reconcile error, got: %v", o.Err)
+	}
+	return o
+}
+
+// AssertError fails the test if the reconciliation returned no error
+func (o *Outcome) AssertError() *Outcome {
+	o.h.t.Helper()
+	if o.Err == nil {
+		o.h.t.Fatalf("expected a reconcile error, got none")
+	}
+	return o
+}
+
+// AssertResult fails the test if the reconciliation returned a different result
+func (o *Outcome) AssertResult(want reconcile.Result) *Outcome {
+	o.h.t.Helper()
+	if o.Result != want {
+		o.h.t.Fatalf("expected the reconcile result %+v, got: %+v", want, o.Result)
+	}
+	return o
+}
+
+// AssertCreated fails the test if the object of the type, namespace and name of obj was not
+// created. The object is otherwise read from the fake client into obj for further assertions
+func (o *Outcome) AssertCreated(obj client.Object) *Outcome {
+	o.h.t.Helper()
+	o.assertAction(obj, VerbCreate)
+	o.get(obj)
+	return o
+}
+
+// AssertUpdated fails the test if the object of the type, namespace and name of obj or its status was not
+// updated or patched. The object is otherwise read from the fake client into obj for further assertions
+func (o *Outcome) AssertUpdated(obj client.Object) *Outcome {
+	o.h.t.Helper()
+	o.assertAction(obj, VerbUpdate, VerbPatch)
+	o.get(obj)
+	return o
+}
+
+// AssertDeleted fails the test if the object of the type, namespace and name of obj was not deleted
+func (o *Outcome) AssertDeleted(obj client.Object) *Outcome {
+	o.h.t.Helper()
+	o.assertAction(obj, VerbDelete)
+	return o
+}
+
+// AssertNotWritten fails the test if the object of the type, namespace and name of obj was written
+func (o *Outcome) AssertNotWritten(obj client.Object) *Outcome {
+	o.h.t.Helper()
+	if action := o.findAction(obj, VerbCreate, VerbUpdate, VerbPatch, VerbDelete); action != nil {
+		o.h.t.Fatalf("expected no write of the %s %s, got: %s", action.Kind, action.Key, action.Verb)
+	}
+	return o
+}
+
+// AssertEvent fails the test if no event of the type and reason was recorded
+func (o *Outcome) AssertEvent(eventType, reason string) *Outcome {
+	o.h.t.Helper()
+	for _, event := range o.Events {
+		if event.Type == eventType && event.Reason == reason {
+			return o
+		}
+	}
+	o.h.t.Fatalf("expected a %s event with the reason %s, got: %+v", eventType, reason, o.Events)
+	return o
+}
+
+// AssertNoEvents fails the test if any event was recorded
+func (o *Outcome) AssertNoEvents() *Outcome {
+	o.h.t.Helper()
+	if len(o.Events) > 0 {
+		o.h.t.Fatalf("expected no events, got: %+v", o.Events)
+	}
+	return o
+}
+
+func (o *Outcome) assertAction(obj client.Object, verbs ...Verb) {
+	o.h.t.Helper()
+	if o.findAction(obj, verbs...) == nil {
+		kind, key := o.identify(obj)
+		o.h.t.Fatalf("expected a %v of the %s %s, got the actions: %s", verbs, kind, key, o.describeActions())
+	}
+}
+
+func (o *Outcome) findAction(obj client.Object, verbs ...Verb) *Action {
+	kind, key := o.identify(obj)
+	for i := range o.Actions {
+		action := &o.Actions[i]
+		if action.Kind != kind || action.Key != key {
+			continue
+		}
+		for _, verb := range verbs {
+			if action.Verb == verb {
+				return action
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Outcome) identify(obj client.Object) (string, types.NamespacedName) {
+	o.h.t.Helper()
+	gvk, err := apiutil.GVKForObject(obj, o.h.scheme)
+	if err != nil {
+		o.h.t.Fatalf("unknown object type %T: %v", obj, err)
+	}
+	return gvk.Kind, client.ObjectKeyFromObject(obj)
+}
+
+func (o *Outcome) get(obj client.Object) {
+	o.h.t.Helper()
+	if err := o.h.fakeClient().Get(context.Background(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		o.h.t.Fatalf("failed to get the %T %s: %v", obj, client.ObjectKeyFromObject(obj), err)
+	}
+}
+
+func (o *Outcome) describeActions() string {
+	desc := ""
+	for _, action := range o.Actions {
+		desc += fmt.Sprintf("\n\t%s %s %s %s", action.Verb, action.Kind, action.Key, action.SubResource)
+	}
+	return desc
+}
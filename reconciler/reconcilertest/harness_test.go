@@ -0,0 +1,165 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconcilertest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/operator-helper/reconciler/reconcilertest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+// configMapReconciler keeps a Secret with the data of each ConfigMap
+type configMapReconciler struct {
+	ctx reconciler.Context
+}
+
+func (r *configMapReconciler) Configure(ctx reconciler.Context) error {
+	r.ctx = ctx
+	return reconciler.SetupWatches(ctx, r, reconciler.WatchSpec{
+		For:  &v1.ConfigMap{},
+		Owns: []client.Object{&v1.Secret{}},
+		References: []reconciler.Reference{{
+			Object:     &v1.Secret{},
+			IndexField: ".data.secret",
+			Extract: func(obj client.Object) []string {
+				return []string{obj.(*v1.ConfigMap).Data["secret"]}
+			},
+		}},
+	})
+}
+
+func (r *configMapReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	cm := &v1.ConfigMap{}
+	return r.ctx.RunWithContext(ctx, req, cm, func(ctx context.Context, deleted bool) (reconciler.Result, error) {
+		if deleted {
+			return reconciler.Done(), nil
+		}
+		if cm.Data["fail"] != "" {
+			return reconciler.Done(), errors.New(cm.Data["fail"])
+		}
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cm.Namespace, Name: cm.Name + "-secret"},
+			Data:       map[string][]byte{},
+		}
+		for key, value := range cm.Data {
+			secret.Data[key] = []byte(value)
+		}
+		if _, err := r.ctx.Apply(ctx, cm, secret); err != nil {
+			return reconciler.Done(), err
+		}
+		return reconciler.Done(), nil
+	})
+}
+
+func newConfigMap(name string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: name, UID: types.UID(name + "-uid")},
+		Data:       data,
+	}
+}
+
+func TestHarness(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme,
+		newConfigMap("app", map[string]string{"key": "value"}),
+		newConfigMap("broken", map[string]string{"fail": "invalid config"}))
+	r := &configMapReconciler{}
+	if err := h.Configure(r); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-secret"}}
+	h.Reconcile(r, types.NamespacedName{Namespace: "test", Name: "app"}).
+		AssertNoError().
+		AssertResult(reconcile.Result{}).
+		AssertCreated(secret).
+		AssertNoEvents()
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].Name != "app" {
+		t.Errorf("expected the ConfigMap owning the Secret, got: %+v", secret.OwnerReferences)
+	}
+	h.Reconcile(r, types.NamespacedName{Namespace: "test", Name: "app"}).
+		AssertNoError().
+		AssertNoEvents().
+		AssertNotWritten(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app"}})
+
+	h.Reconcile(r, types.NamespacedName{Namespace: "test", Name: "broken"}).
+		AssertError().
+		AssertResult(reconcile.Result{}).
+		AssertEvent(v1.EventTypeWarning, reconciler.EventReasonReconcileFailed).
+		AssertNotWritten(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "broken-secret"}})
+
+	h.Reconcile(r, types.NamespacedName{Namespace: "test", Name: "missing"}).
+		AssertNoError().
+		AssertResult(reconcile.Result{}).
+		AssertNoEvents()
+}
+
+func TestHarnessRecordsUpdates(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme, newConfigMap("app", map[string]string{"key": "value"}))
+	r := &configMapReconciler{}
+	if err := h.Configure(r); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	key := types.NamespacedName{Namespace: "test", Name: "app"}
+	h.Reconcile(r, key).AssertNoError()
+
+	cm := &v1.ConfigMap{}
+	if err := h.Client().Get(context.Background(), key, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["key"] = "changed"
+	if err := h.Client().Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "app-secret"}}
+	h.Reconcile(r, key).AssertNoError().AssertUpdated(secret)
+	if got := string(secret.Data["key"]); got != "changed" {
+		t.Errorf("expected the updated Secret data, got: %q", got)
+	}
+}
+
+func TestHarnessFieldIndexes(t *testing.T) {
+	h := reconcilertest.New(t, clientgoscheme.Scheme,
+		newConfigMap("first", map[string]string{"secret": "password"}),
+		newConfigMap("second", map[string]string{"secret": "other"}),
+		newConfigMap("third", map[string]string{"secret": "password"}))
+	if err := h.Configure(&configMapReconciler{}); err != nil {
+		t.Fatalf("configure error: %v", err)
+	}
+	list := &v1.ConfigMapList{}
+	if err := h.Client().List(context.Background(), list, client.InNamespace("test"),
+		client.MatchingFields{".data.secret": "password"}); err != nil {
+		t.Fatalf("list by the index error: %v", err)
+	}
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	if len(names) != 2 || names[0] != "first" || names[1] != "third" {
+		t.Errorf("expected the ConfigMaps referencing the Secret, got: %v", names)
+	}
+	// the client is built with the indexes registered so far
+	if err := h.Configure(&configMapReconciler{}); err == nil {
+		t.Errorf("expected an error registering an index once the client is used")
+	}
+}
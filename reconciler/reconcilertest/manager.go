@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconcilertest
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// fakeManager provides a reconciler.Context with the fake client of the Harness. Configuring a Reconciler, e.g.
+// with reconciler.SetupWatches, builds its controller but the controller is never started: the Harness calls
+// the Reconciler directly. The manager methods not needed to configure a Reconciler are not supported
+type fakeManager struct {
+	manager.Manager
+	harness       *Harness
+	restMapper    meta.RESTMapper
+	logger        logr.Logger
+	webhookServer webhook.Server
+}
+
+func newFakeManager(h *Harness, restMapper meta.RESTMapper, logger logr.Logger) *fakeManager {
+	return &fakeManager{
+		harness:       h,
+		restMapper:    restMapper,
+		logger:        logger,
+		webhookServer: webhook.NewServer(webhook.Options{}),
+	}
+}
+
+func (m *fakeManager) GetClient() client.Client {
+	return m.harness.fakeClient()
+}
+
+func (m *fakeManager) GetAPIReader() client.Reader {
+	return m.harness.fakeClient()
+}
+
+func (m *fakeManager) GetScheme() *runtime.Scheme {
+	return m.harness.scheme
+}
+
+func (m *fakeManager) GetRESTMapper() meta.RESTMapper {
+	return m.restMapper
+}
+
+func (m *fakeManager) GetConfig() *rest.Config {
+	return &rest.Config{}
+}
+
+func (m *fakeManager) GetLogger() logr.Logger {
+	return m.logger
+}
+
+func (m *fakeManager) GetEventRecorderFor(string) record.EventRecorder {
+	return NewRecorder()
+}
+
+// GetCache returns no cache; the watches of the controllers are never started
+func (m *fakeManager) GetCache() cache.Cache {
+	return nil
+}
+
+// GetFieldIndexer returns an indexer registering the indexes on the fake client of the Harness
+func (m *fakeManager) GetFieldIndexer() client.FieldIndexer {
+	return fieldIndexer{harness: m.harness}
+}
+
+func (m *fakeManager) GetControllerOptions() config.Controller {
+	return config.Controller{}
+}
+
+func (m *fakeManager) GetWebhookServer() webhook.Server {
+	return m.webhookServer
+}
+
+// Add accepts the runnables, e.g. the controllers, without starting them
+func (m *fakeManager) Add(manager.Runnable) error {
+	return nil
+}
+
+type fieldIndexer struct {
+	harness *Harness
+}
+
+func (i fieldIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	return i.harness.indexField(obj, field, extractValue)
+}
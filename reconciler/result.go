@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// Result tells Run whether and when to requeue the request after a successful reconciliation
+type Result struct {
+	requeue      bool
+	requeueAfter time.Duration
+}
+
+// Done returns a Result that completes the reconciliation without requeue
+func Done() Result {
+	return Result{}
+}
+
+// RequeueNow returns a Result that requeues the request immediately
+func RequeueNow() Result {
+	return Result{requeue: true}
+}
+
+// RequeueAfter returns a Result that requeues the request after the duration, e.g. to
+// periodically re-check a rolling StatefulSet. A non-positive duration requeues immediately
+func RequeueAfter(duration time.Duration) Result {
+	if duration <= 0 {
+		return RequeueNow()
+	}
+	return Result{requeue: true, requeueAfter: duration}
+}
+
+// IsDone checks if the Result completes the reconciliation without requeue
+func (r Result) IsDone() bool {
+	return !r.requeue
+}
+
+func (r Result) toReconcileResult() reconcile.Result {
+	return reconcile.Result{
+		Requeue:      r.requeue,
+		RequeueAfter: r.requeueAfter,
+	}
+}
+
+// Terminal marks the error as terminal. Run reports the error but does not requeue the request
+func Terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &terminalError{err: err}
+}
+
+// Transient marks the error as transient. Run requeues the request with the rate limited backoff
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTerminal checks if the error or any error it wraps is marked terminal
+func IsTerminal(err error) bool {
+	var te *terminalError
+	return errors.As(err, &te)
+}
+
+// IsTransient checks if the error is not terminal and therefore should be retried
+func IsTransient(err error) bool {
+	return err != nil && !IsTerminal(err)
+}
+
+type terminalError struct {
+	err error
+}
+
+func (e *terminalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *terminalError) Unwrap() error {
+	return e.err
+}
+
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
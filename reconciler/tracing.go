@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// tracingClient traces every API call made within a reconciliation as a child span of its Run span
+type tracingClient struct {
+	client.Client
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) (err error) {
+	ctx, span := c.start(ctx, "Get", obj, key.Namespace, key.Name, "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	options := &client.ListOptions{}
+	options.ApplyOptions(opts)
+	ctx, span := c.start(ctx, "List", list, options.Namespace, "", "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj, obj.GetNamespace(), obj.GetName(), "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj, obj.GetNamespace(), obj.GetName(), "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj, obj.GetNamespace(), obj.GetName(), "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", obj, obj.GetNamespace(), obj.GetName(), "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *tracingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) (err error) {
+	options := &client.DeleteAllOfOptions{}
+	options.ApplyOptions(opts)
+	ctx, span := c.start(ctx, "DeleteAllOf", obj, options.Namespace, "", "")
+	defer func() { tracing.End(span, err) }()
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *tracingClient) SubResource(subResource string) client.SubResourceClient {
+	return &tracingSubResourceClient{
+		SubResourceClient: c.Client.SubResource(subResource),
+		parent:            c,
+		subResource:       subResource,
+	}
+}
+
+// start starts the span of the API call named after the verb e.g. "Get ConfigMap"
+func (c *tracingClient) start(ctx context.Context, verb string, obj runtime.Object, namespace, name,
+	subResource string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return tracing.StartChild(ctx, verb)
+	}
+	kind := "unknown"
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("k8s.kind", kind),
+		attribute.String("k8s.namespace", namespace),
+	}
+	if name != "" {
+		attrs = append(attrs, attribute.String("k8s.name", name))
+	}
+	if subResource != "" {
+		attrs = append(attrs, attribute.String("k8s.subresource", subResource))
+	}
+	return tracing.StartChild(ctx, verb+" "+kind, attrs...)
+}
+
+type tracingSubResourceClient struct {
+	client.SubResourceClient
+	parent      *tracingClient
+	subResource string
+}
+
+func (c *tracingSubResourceClient) Get(ctx context.Context, obj, subResource client.Object,
+	opts ...client.SubResourceGetOption) (err error) {
+	ctx, span := c.parent.start(ctx, "Get", obj, obj.GetNamespace(), obj.GetName(), c.subResource)
+	defer func() { tracing.End(span, err) }()
+	return c.SubResourceClient.Get(ctx, obj, subResource, opts...)
+}
+
+func (c *tracingSubResourceClient) Create(ctx context.Context, obj, subResource client.Object,
+	opts ...client.SubResourceCreateOption) (err error) {
+	ctx, span := c.parent.start(ctx, "Create", obj, obj.GetNamespace(), obj.GetName(), c.subResource)
+	defer func() { tracing.End(span, err) }()
+	return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (c *tracingSubResourceClient) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) (err error) {
+	ctx, span := c.parent.start(ctx, "Update", obj, obj.GetNamespace(), obj.GetName(), c.subResource)
+	defer func() { tracing.End(span, err) }()
+	return c.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (c *tracingSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) (err error) {
+	ctx, span := c.parent.start(ctx, "Patch", obj, obj.GetNamespace(), obj.GetName(), c.subResource)
+	defer func() { tracing.End(span, err) }()
+	return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// WatchSpec declares the resources watched by the controller of a Reconciler
+type WatchSpec struct {
+	// For is the type of the reconciled object e.g. &v1alpha1.ZookeeperCluster{}
+	For client.Object
+	// Predicates filter the events of the reconciled object e.g. IgnoreStatusUpdates()
+	Predicates []predicate.Predicate
+	// Owns are the types of the objects owned by the reconciled object e.g. &v1.StatefulSet{}
+	Owns []client.Object
+	// References are the non-owned objects referenced by the reconciled object
+	References []Reference
+}
+
+// Reference declares a watch of the non-owned objects referenced by the reconciled object, e.g. a
+// password Secret. The reconciled objects are indexed by the names of the objects they reference,
+// so a change of a referenced object triggers a reconciliation of every object of its namespace using it
+type Reference struct {
+	// Object is the type of the referenced object e.g. &v1.Secret{}
+	Object client.Object
+	// IndexField is the name of the field index of the reconciled objects e.g. ".spec.passwordSecret"
+	IndexField string
+	// Extract returns the names of the objects of the type referenced by the reconciled object
+	Extract client.IndexerFunc
+}
+
+// SetupWatches creates a controller of the Reconciler watching the resources declared by the spec
+func SetupWatches(ctx Context, r reconcile.Reconciler, spec WatchSpec) error {
+	bldr := ctx.NewControllerBuilder().For(spec.For, builder.WithPredicates(spec.Predicates...))
+	for _, owned := range spec.Owns {
+		bldr = bldr.Owns(owned)
+	}
+	for _, ref := range spec.References {
+		if err := ctx.FieldIndexer().IndexField(context.Background(), spec.For, ref.IndexField, ref.Extract); err != nil {
+			return fmt.Errorf("index field %s error: %w", ref.IndexField, err)
+		}
+		mapFunc, err := referrersMapFunc(ctx, spec.For, ref.IndexField)
+		if err != nil {
+			return err
+		}
+		bldr = bldr.Watches(ref.Object, handler.EnqueueRequestsFromMapFunc(mapFunc))
+	}
+	return bldr.Complete(r)
+}
+
+// referrersMapFunc maps a referenced object to the requests of the objects of its namespace referencing it
+func referrersMapFunc(ctx Context, forType client.Object, indexField string) (handler.MapFunc, error) {
+	gvk, err := apiutil.GVKForObject(forType, ctx.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+	return func(c context.Context, referenced client.Object) []reconcile.Request {
+		obj, err := ctx.Scheme().New(listGVK)
+		if err != nil {
+			ctx.Logger().Error(err, "Failed to create the list", "kind", listGVK.Kind)
+			return nil
+		}
+		list := obj.(client.ObjectList)
+		if err = ctx.Client().List(c, list, client.InNamespace(referenced.GetNamespace()),
+			client.MatchingFields{indexField: referenced.GetName()}); err != nil {
+			ctx.Logger().Error(err, "Failed to list the referencing objects",
+				"kind", gvk.Kind, "index", indexField, "referenced", referenced.GetName())
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			ctx.Logger().Error(err, "Failed to extract the referencing objects", "kind", gvk.Kind)
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			if accessor, err := meta.Accessor(item); err == nil {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()},
+				})
+			}
+		}
+		return requests
+	}, nil
+}
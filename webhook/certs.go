@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// The keys of the certificates Secret
+const (
+	SecretCACertKey  = "ca.crt"
+	SecretCAKeyKey   = "ca.key"
+	SecretTLSCertKey = "tls.crt"
+	SecretTLSKeyKey  = "tls.key"
+)
+
+// certificates are the PEM encoded CA and serving certificate of the webhook server
+type certificates struct {
+	// caBundle is the current CA certificate, followed by the previous one during a rotation
+	caBundle []byte
+	caKey    []byte
+	cert     []byte
+	key      []byte
+}
+
+func certificatesFromSecretData(data map[string][]byte) (*certificates, error) {
+	certs := &certificates{
+		caBundle: data[SecretCACertKey],
+		caKey:    data[SecretCAKeyKey],
+		cert:     data[SecretTLSCertKey],
+		key:      data[SecretTLSKeyKey],
+	}
+	if len(certs.caBundle) == 0 || len(certs.caKey) == 0 || len(certs.cert) == 0 || len(certs.key) == 0 {
+		return nil, errors.New("incomplete certificates secret")
+	}
+	return certs, nil
+}
+
+func (c *certificates) secretData() map[string][]byte {
+	return map[string][]byte{
+		SecretCACertKey:  c.caBundle,
+		SecretCAKeyKey:   c.caKey,
+		SecretTLSCertKey: c.cert,
+		SecretTLSKeyKey:  c.key,
+	}
+}
+
+// expiry returns the earliest expiry of the current CA and the serving certificate
+func (c *certificates) expiry() (time.Time, error) {
+	ca, err := parseCertificate(c.caBundle)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cert, err := parseCertificate(c.cert)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ca.NotAfter.Before(cert.NotAfter) {
+		return ca.NotAfter, nil
+	}
+	return cert.NotAfter, nil
+}
+
+// generateCertificates generates a new CA and a serving certificate for the DNS names signed by it.
+// The current CA of the previous certificates, if still valid, is kept in the CA bundle so clients
+// trusting either CA accept the server during the rotation
+func generateCertificates(dnsNames []string, validity time.Duration, previous *certificates) (*certificates, error) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca", dnsNames[0])},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	caKeyPEM, err := encodeKey(caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	if previous != nil {
+		if previousCA, err := parseCertificate(previous.caBundle); err == nil && now.Before(previousCA.NotAfter) {
+			caBundle = append(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: previousCA.Raw})...)
+		}
+	}
+	return &certificates{
+		caBundle: caBundle,
+		caKey:    caKeyPEM,
+		cert:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		key:      keyPEM,
+	}, nil
+}
+
+// parseCertificate parses the first certificate of the PEM data
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		// crypto/rand does not fail on the supported platforms
+		panic(err)
+	}
+	return serial
+}
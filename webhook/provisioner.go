@@ -0,0 +1,331 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/monimesl/operator-helper/config"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"time"
+)
+
+const (
+	// DefaultCertValidity is the validity of the provisioned certificates
+	DefaultCertValidity = 365 * 24 * time.Hour
+	// DefaultCertRotateBefore is how long before their expiry the provisioned certificates are rotated
+	DefaultCertRotateBefore = 30 * 24 * time.Hour
+	// DefaultCertCheckInterval is how often the provisioned certificates are checked
+	DefaultCertCheckInterval = time.Hour
+)
+
+// CertProvisioner provisions a self-signed CA and a serving certificate for the DNS names of the
+// webhook Service, for clusters without e.g. cert-manager. The certificates are stored in a Secret
+// shared by the operator replicas, written to the cert directory of the webhook server and the CA
+// is patched into the caBundle of the validating and mutating webhooks of the Service. It needs the
+// RBAC to get, create and update the Secret and to list and patch the webhook configurations
+type CertProvisioner struct {
+	ServiceName   string
+	Namespace     string
+	SecretName    string
+	CertDir       string
+	Validity      time.Duration
+	RotateBefore  time.Duration
+	CheckInterval time.Duration
+
+	client client.Client
+	logger logr.Logger
+}
+
+// SetupCertProvisioning provisions the webhook certificates and adds their rotation to the manager
+// when enabled in config. It must be called before the manager starts the webhook server
+func SetupCertProvisioning(mgr manager.Manager) error {
+	if !config.WebHookCertProvisioningEnabled() {
+		return nil
+	}
+	provisioner, err := NewCertProvisioner(mgr)
+	if err != nil {
+		return err
+	}
+	if provisioner.ServiceName == "" || provisioner.Namespace == "" {
+		return fmt.Errorf("webhook certificates provisioning needs the webhook service name and namespace, got: %q and %q",
+			provisioner.ServiceName, provisioner.Namespace)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = provisioner.Provision(ctx); err != nil {
+		return fmt.Errorf("webhook certificates provisioning error: %w", err)
+	}
+	return provisioner.AddToManager(mgr)
+}
+
+// NewCertProvisioner creates a CertProvisioner of the webhook Service and Secret set in config.
+// It uses its own uncached client, so it works before the manager starts
+func NewCertProvisioner(mgr manager.Manager) (*CertProvisioner, error) {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := admissionregistrationv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	cl, err := client.New(mgr.GetConfig(), client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	return &CertProvisioner{
+		ServiceName:   config.WebHookServiceName(),
+		Namespace:     config.WebHookServiceNamespace(),
+		SecretName:    config.WebHookCertSecretName(),
+		CertDir:       config.GetWebHookCertDir(),
+		Validity:      DefaultCertValidity,
+		RotateBefore:  DefaultCertRotateBefore,
+		CheckInterval: DefaultCertCheckInterval,
+		client:        cl,
+		logger:        mgr.GetLogger().WithName("webhook-cert-provisioner"),
+	}, nil
+}
+
+// DNSNames returns the DNS names of the webhook Service the serving certificate is issued for
+func (p *CertProvisioner) DNSNames() []string {
+	return []string{
+		fmt.Sprintf("%s.%s.svc", p.ServiceName, p.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", p.ServiceName, p.Namespace),
+		fmt.Sprintf("%s.%s", p.ServiceName, p.Namespace),
+		p.ServiceName,
+	}
+}
+
+// Provision creates the certificates Secret if it does not exist or its certificates are unusable,
+// patches the caBundle of the webhooks and writes the certificates to the cert directory.
+// Certificates about to expire are left to the rotation of the leader
+func (p *CertProvisioner) Provision(ctx context.Context) error {
+	return p.ensure(ctx, false)
+}
+
+// AddToManager adds the rotation of the certificates, run by the leader only,
+// and the sync of the cert directory with the Secret, run by every replica
+func (p *CertProvisioner) AddToManager(mgr manager.Manager) error {
+	if err := mgr.Add(&certRotator{provisioner: p}); err != nil {
+		return err
+	}
+	return mgr.Add(&certSyncer{provisioner: p})
+}
+
+func (p *CertProvisioner) ensure(ctx context.Context, rotate bool) error {
+	var certs *certificates
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		certs, err = p.ensureSecret(ctx, rotate)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// the API server must trust the new CA before the webhook server serves the certificate it signed
+	if err = p.patchCABundles(ctx, certs.caBundle); err != nil {
+		return err
+	}
+	return p.writeFiles(certs)
+}
+
+// ensureSecret gets the certificates of the Secret, creating or updating it with new ones if needed
+func (p *CertProvisioner) ensureSecret(ctx context.Context, rotate bool) (*certificates, error) {
+	secret := &v1.Secret{}
+	err := p.client.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.SecretName}, secret)
+	if errors.IsNotFound(err) {
+		certs, err := generateCertificates(p.DNSNames(), p.Validity, nil)
+		if err != nil {
+			return nil, err
+		}
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.SecretName},
+			Type:       v1.SecretTypeOpaque,
+			Data:       certs.secretData(),
+		}
+		p.logger.Info("Creating the webhook certificates secret", "secret", p.SecretName)
+		if err = p.client.Create(ctx, secret); errors.IsAlreadyExists(err) {
+			// created by another replica; read it on the retry
+			return nil, errors.NewConflict(v1.Resource("secrets"), p.SecretName, err)
+		}
+		return certs, err
+	} else if err != nil {
+		return nil, err
+	}
+	certs, err := certificatesFromSecretData(secret.Data)
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	} else if expiry, err := certs.expiry(); err != nil {
+		reason = err.Error()
+	} else if time.Now().After(expiry) {
+		reason = "expired"
+	} else if rotate && time.Until(expiry) < p.RotateBefore {
+		reason = fmt.Sprintf("expiring at %s", expiry.Format(time.RFC3339))
+	}
+	if reason == "" {
+		return certs, nil
+	}
+	newCerts, err := generateCertificates(p.DNSNames(), p.Validity, certs)
+	if err != nil {
+		return nil, err
+	}
+	p.logger.Info("Rotating the webhook certificates", "secret", p.SecretName, "reason", reason)
+	secret.Data = newCerts.secretData()
+	if err = p.client.Update(ctx, secret); err != nil {
+		return nil, err
+	}
+	return newCerts, nil
+}
+
+// writeFiles writes the serving certificate and key to the cert directory if they changed;
+// the webhook server watches the files and reloads them
+func (p *CertProvisioner) writeFiles(certs *certificates) error {
+	if err := os.MkdirAll(p.CertDir, 0700); err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		SecretTLSCertKey: certs.cert,
+		SecretTLSKeyKey:  certs.key,
+	}
+	for name, data := range files {
+		path := filepath.Join(p.CertDir, name)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			continue
+		}
+		// replace the file atomically so the webhook server never reads a partial one
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		p.logger.Info("Wrote the webhook certificate file", "file", path)
+	}
+	return nil
+}
+
+// patchCABundles sets the caBundle of every validating and mutating webhook calling the Service
+func (p *CertProvisioner) patchCABundles(ctx context.Context, caBundle []byte) error {
+	validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := p.client.List(ctx, validating); err != nil {
+		return err
+	}
+	for i := range validating.Items {
+		configuration := &validating.Items[i]
+		original := configuration.DeepCopy()
+		changed := false
+		for j := range configuration.Webhooks {
+			changed = p.setCABundle(&configuration.Webhooks[j].ClientConfig, caBundle) || changed
+		}
+		if err := p.patchConfiguration(ctx, changed, configuration, original); err != nil {
+			return err
+		}
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	if err := p.client.List(ctx, mutating); err != nil {
+		return err
+	}
+	for i := range mutating.Items {
+		configuration := &mutating.Items[i]
+		original := configuration.DeepCopy()
+		changed := false
+		for j := range configuration.Webhooks {
+			changed = p.setCABundle(&configuration.Webhooks[j].ClientConfig, caBundle) || changed
+		}
+		if err := p.patchConfiguration(ctx, changed, configuration, original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *CertProvisioner) setCABundle(clientConfig *admissionregistrationv1.WebhookClientConfig, caBundle []byte) bool {
+	service := clientConfig.Service
+	if service == nil || service.Name != p.ServiceName || service.Namespace != p.Namespace ||
+		bytes.Equal(clientConfig.CABundle, caBundle) {
+		return false
+	}
+	clientConfig.CABundle = caBundle
+	return true
+}
+
+func (p *CertProvisioner) patchConfiguration(ctx context.Context, changed bool, configuration, original client.Object) error {
+	if !changed {
+		return nil
+	}
+	p.logger.Info("Patching the caBundle of the webhook configuration",
+		"configuration", configuration.GetName())
+	return p.client.Patch(ctx, configuration, client.MergeFrom(original))
+}
+
+// certRotator rotates the certificates before their expiry on the leader
+type certRotator struct {
+	provisioner *CertProvisioner
+}
+
+func (r *certRotator) NeedLeaderElection() bool {
+	return true
+}
+
+func (r *certRotator) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.provisioner.ensure(ctx, true); err != nil {
+			r.provisioner.logger.Error(err, "Failed to rotate the webhook certificates")
+		}
+	}, r.provisioner.CheckInterval)
+	return nil
+}
+
+// certSyncer writes the certificates rotated by the leader to the cert directory of every replica
+type certSyncer struct {
+	provisioner *CertProvisioner
+}
+
+func (s *certSyncer) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *certSyncer) Start(ctx context.Context) error {
+	p := s.provisioner
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		secret := &v1.Secret{}
+		err := p.client.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.SecretName}, secret)
+		if err == nil {
+			var certs *certificates
+			if certs, err = certificatesFromSecretData(secret.Data); err == nil {
+				err = p.writeFiles(certs)
+			}
+		}
+		if err != nil {
+			p.logger.Error(err, "Failed to sync the webhook certificate files")
+		}
+	}, p.CheckInterval)
+	return nil
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-logr/logr"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func newTestProvisioner(t *testing.T, objects ...client.Object) *CertProvisioner {
+	scheme := runtime.NewScheme()
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := admissionregistrationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	webhooks := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-webhooks"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name: "vtest.example.com",
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Namespace: "test", Name: "test-webhook"},
+				},
+			},
+			{
+				Name: "vother.example.com",
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Namespace: "test", Name: "other-webhook"},
+				},
+			},
+		},
+	}
+	return &CertProvisioner{
+		ServiceName:   "test-webhook",
+		Namespace:     "test",
+		SecretName:    "test-webhook-cert",
+		CertDir:       t.TempDir(),
+		Validity:      DefaultCertValidity,
+		RotateBefore:  DefaultCertRotateBefore,
+		CheckInterval: DefaultCertCheckInterval,
+		client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objects, webhooks)...).Build(),
+		logger:        logr.Discard(),
+	}
+}
+
+func (p *CertProvisioner) testSecret(t *testing.T) *certificates {
+	secret := &v1.Secret{}
+	if err := p.client.Get(context.Background(), client.ObjectKey{Namespace: p.Namespace, Name: p.SecretName}, secret); err != nil {
+		t.Fatal(err)
+	}
+	certs, err := certificatesFromSecretData(secret.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certs
+}
+
+func (p *CertProvisioner) testCABundles(t *testing.T) (ours, other []byte) {
+	configuration := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := p.client.Get(context.Background(), client.ObjectKey{Name: "test-webhooks"}, configuration); err != nil {
+		t.Fatal(err)
+	}
+	return configuration.Webhooks[0].ClientConfig.CABundle, configuration.Webhooks[1].ClientConfig.CABundle
+}
+
+func TestProvision(t *testing.T) {
+	p := newTestProvisioner(t)
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	certs := p.testSecret(t)
+	cert, err := parseCertificate(certs.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cert.VerifyHostname("test-webhook.test.svc"); err != nil {
+		t.Errorf("serving certificate not issued for the service: %v", err)
+	}
+	ours, other := p.testCABundles(t)
+	if !bytes.Equal(ours, certs.caBundle) {
+		t.Errorf("caBundle of the service webhook not patched")
+	}
+	if len(other) != 0 {
+		t.Errorf("caBundle of another service webhook patched")
+	}
+	written, err := os.ReadFile(filepath.Join(p.CertDir, SecretTLSCertKey))
+	if err != nil || !bytes.Equal(written, certs.cert) {
+		t.Errorf("serving certificate not written: %v", err)
+	}
+	// the certificates of the Secret are reused
+	if err = p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.testSecret(t).cert, certs.cert) {
+		t.Errorf("valid certificates regenerated")
+	}
+}
+
+func TestProvisionPatchesCABundleBeforeWritingFiles(t *testing.T) {
+	p := newTestProvisioner(t)
+	// a file in place of the cert directory fails the write
+	p.CertDir = filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(p.CertDir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Provision(context.Background()); err == nil {
+		t.Fatal("expected an error writing the certificate files")
+	}
+	if ours, _ := p.testCABundles(t); !bytes.Equal(ours, p.testSecret(t).caBundle) {
+		t.Errorf("caBundle not patched before writing the certificate files")
+	}
+}
+
+func TestEnsureRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		validity time.Duration
+		rotate   bool
+		rotated  bool
+	}{
+		{name: "valid", validity: DefaultCertValidity, rotate: true, rotated: false},
+		{name: "expiring", validity: 24 * time.Hour, rotate: true, rotated: true},
+		{name: "expiring without rotation", validity: 24 * time.Hour, rotate: false, rotated: false},
+		{name: "expired", validity: -time.Minute, rotate: false, rotated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvisioner(t)
+			previous, err := generateCertificates(p.DNSNames(), tt.validity, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.SecretName},
+				Data:       previous.secretData(),
+			}
+			if err = p.client.Create(context.Background(), secret); err != nil {
+				t.Fatal(err)
+			}
+			if err = p.ensure(context.Background(), tt.rotate); err != nil {
+				t.Fatal(err)
+			}
+			certs := p.testSecret(t)
+			if rotated := !bytes.Equal(certs.cert, previous.cert); rotated != tt.rotated {
+				t.Fatalf("expected rotated: %v, got: %v", tt.rotated, rotated)
+			}
+			// a still valid previous CA stays trusted during the rotation
+			keepsPrevious := bytes.Contains(certs.caBundle, bytes.TrimSpace(previous.caBundle))
+			if wantPrevious := tt.validity > 0; keepsPrevious != wantPrevious {
+				t.Errorf("expected the previous CA in the bundle: %v, got: %v", wantPrevious, keepsPrevious)
+			}
+		})
+	}
+}
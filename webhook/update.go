@@ -0,0 +1,156 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+// UpdateRule validates a field of an updated object against its old value
+type UpdateRule struct {
+	// Path of the field e.g. spec.storageClassName. A [*] segment matches every element
+	// of a list, compared by index to the old one e.g. spec.volumes[*].size
+	Path string
+	// Check validates the new value of the field; a value is nil when the field is not set
+	Check func(path *field.Path, oldValue, newValue interface{}) field.ErrorList
+}
+
+// Immutable creates a rule rejecting any change of the field once the object is created
+func Immutable(path string) UpdateRule {
+	return UpdateRule{
+		Path: path,
+		Check: func(path *field.Path, oldValue, newValue interface{}) field.ErrorList {
+			return apivalidation.ValidateImmutableField(newValue, oldValue, path)
+		},
+	}
+}
+
+// IncreaseOnly creates a rule rejecting the decrease of a resource.Quantity field e.g. a volume size.
+// Setting the field is allowed but not unsetting it
+func IncreaseOnly(path string) UpdateRule {
+	return UpdateRule{
+		Path: path,
+		Check: func(path *field.Path, oldValue, newValue interface{}) field.ErrorList {
+			if oldValue == nil {
+				return nil
+			}
+			if newValue == nil {
+				return field.ErrorList{field.Forbidden(path, "field can not be unset")}
+			}
+			oldQuantity, err := resource.ParseQuantity(fmt.Sprint(oldValue))
+			if err != nil {
+				// the old value was not validated; accept any valid one
+				return nil
+			}
+			newQuantity, err := resource.ParseQuantity(fmt.Sprint(newValue))
+			if err != nil {
+				return field.ErrorList{field.Invalid(path, newValue, err.Error())}
+			}
+			if newQuantity.Cmp(oldQuantity) < 0 {
+				return field.ErrorList{field.Invalid(path, newQuantity.String(),
+					fmt.Sprintf("field can not be less than its previous value %s", oldQuantity.String()))}
+			}
+			return nil
+		},
+	}
+}
+
+// ValidateUpdate is Validate for the update of an object. The rules are checked between
+// the old and new objects and their errors are aggregated with those of the validator funcs
+func ValidateUpdate(gvk schema.GroupVersionKind, oldObj, newObj runtime.Object, rules []UpdateRule, validatorFuncs ...func(list *ErrorList)) error {
+	_, err := ValidateUpdateWithWarnings(gvk, oldObj, newObj, rules, validatorFuncs...)
+	return err
+}
+
+// ValidateUpdateWithWarnings is ValidateUpdate also returning the warnings added to the error list
+func ValidateUpdateWithWarnings(gvk schema.GroupVersionKind, oldObj, newObj runtime.Object, rules []UpdateRule, validatorFuncs ...func(list *ErrorList)) (admission.Warnings, error) {
+	name := ""
+	if accessor, err := meta.Accessor(newObj); err == nil {
+		name = accessor.GetName()
+	}
+	funcs := append([]func(list *ErrorList){CheckUpdateRules(oldObj, newObj, rules...)}, validatorFuncs...)
+	return ValidateWithWarnings(gvk, name, funcs...)
+}
+
+// CheckUpdateRules creates a validator func checking the rules between the old and new objects
+func CheckUpdateRules(oldObj, newObj runtime.Object, rules ...UpdateRule) func(list *ErrorList) {
+	return func(list *ErrorList) {
+		oldMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(oldObj)
+		if err != nil {
+			list.Add(field.InternalError(field.NewPath("metadata"), err))
+			return
+		}
+		newMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newObj)
+		if err != nil {
+			list.Add(field.InternalError(field.NewPath("metadata"), err))
+			return
+		}
+		for _, rule := range rules {
+			segments := strings.Split(rule.Path, ".")
+			checkRule(list, rule, nil, segments, oldMap, newMap)
+		}
+	}
+}
+
+// checkRule walks the segments of the rule path down the old and new values
+func checkRule(list *ErrorList, rule UpdateRule, path *field.Path, segments []string, oldValue, newValue interface{}) {
+	if len(segments) == 0 {
+		list.AddAll(rule.Check(path, oldValue, newValue))
+		return
+	}
+	name := segments[0]
+	each := strings.HasSuffix(name, "[*]")
+	name = strings.TrimSuffix(name, "[*]")
+	path = childPath(path, name)
+	oldChild := mapValue(oldValue, name)
+	newChild := mapValue(newValue, name)
+	if !each {
+		checkRule(list, rule, path, segments[1:], oldChild, newChild)
+		return
+	}
+	oldList, _ := oldChild.([]interface{})
+	newList, _ := newChild.([]interface{})
+	for i := range newList {
+		if i >= len(oldList) {
+			// an added element has no old value to check against
+			break
+		}
+		checkRule(list, rule, path.Index(i), segments[1:], oldList[i], newList[i])
+	}
+}
+
+func childPath(path *field.Path, name string) *field.Path {
+	if path == nil {
+		return field.NewPath(name)
+	}
+	return path.Child(name)
+}
+
+func mapValue(value interface{}, key string) interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
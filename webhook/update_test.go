@@ -0,0 +1,181 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testClaim struct {
+	storageClass string
+	size         string
+}
+
+func testStatefulSet(claims ...testClaim) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "test"}}
+	for _, claim := range claims {
+		pvc := v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data"}}
+		if claim.storageClass != "" {
+			storageClass := claim.storageClass
+			pvc.Spec.StorageClassName = &storageClass
+		}
+		if claim.size != "" {
+			pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse(claim.size)}
+		}
+		sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, pvc)
+	}
+	return sts
+}
+
+func TestCheckUpdateRules(t *testing.T) {
+	rules := []UpdateRule{
+		Immutable("spec.serviceName"),
+		Immutable("spec.volumeClaimTemplates[*].spec.storageClassName"),
+		IncreaseOnly("spec.volumeClaimTemplates[*].spec.resources.requests.storage"),
+	}
+	tests := []struct {
+		name   string
+		old    *appsv1.StatefulSet
+		new    *appsv1.StatefulSet
+		errors []string
+	}{
+		{
+			name: "unchanged",
+			old:  testStatefulSet(testClaim{"fast", "1Gi"}),
+			new:  testStatefulSet(testClaim{"fast", "1Gi"}),
+		},
+		{
+			name: "size increased",
+			old:  testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "1Gi"}),
+			new:  testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "2Gi"}),
+		},
+		{
+			name: "size canonicalized",
+			old:  testStatefulSet(testClaim{"fast", "1Gi"}),
+			new:  testStatefulSet(testClaim{"fast", "1024Mi"}),
+		},
+		{
+			name:   "size decreased",
+			old:    testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "2Gi"}),
+			new:    testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "1Gi"}),
+			errors: []string{"spec.volumeClaimTemplates[1].spec.resources.requests.storage: Invalid value"},
+		},
+		{
+			name:   "size unset",
+			old:    testStatefulSet(testClaim{"fast", "1Gi"}),
+			new:    testStatefulSet(testClaim{"fast", ""}),
+			errors: []string{"spec.volumeClaimTemplates[0].spec.resources.requests.storage: Forbidden"},
+		},
+		{
+			name: "size set",
+			old:  testStatefulSet(testClaim{"fast", ""}),
+			new:  testStatefulSet(testClaim{"fast", "1Gi"}),
+		},
+		{
+			name:   "storage class changed",
+			old:    testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "1Gi"}),
+			new:    testStatefulSet(testClaim{"slow", "1Gi"}, testClaim{"fast", "1Gi"}),
+			errors: []string{"spec.volumeClaimTemplates[0].spec.storageClassName: Invalid value"},
+		},
+		{
+			name:   "storage class set",
+			old:    testStatefulSet(testClaim{"", "1Gi"}),
+			new:    testStatefulSet(testClaim{"fast", "1Gi"}),
+			errors: []string{"spec.volumeClaimTemplates[0].spec.storageClassName: Invalid value"},
+		},
+		{
+			name: "claim added",
+			old:  testStatefulSet(testClaim{"fast", "1Gi"}),
+			new:  testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"slow", "1Mi"}),
+		},
+		{
+			name: "claim removed",
+			old:  testStatefulSet(testClaim{"fast", "1Gi"}, testClaim{"fast", "1Gi"}),
+			new:  testStatefulSet(testClaim{"fast", "1Gi"}),
+		},
+		{
+			name: "every claim changed",
+			old:  testStatefulSet(testClaim{"fast", "2Gi"}, testClaim{"fast", "2Gi"}),
+			new:  testStatefulSet(testClaim{"slow", "1Gi"}, testClaim{"fast", "1Gi"}),
+			errors: []string{
+				"spec.volumeClaimTemplates[0].spec.storageClassName: Invalid value",
+				"spec.volumeClaimTemplates[0].spec.resources.requests.storage: Invalid value",
+				"spec.volumeClaimTemplates[1].spec.resources.requests.storage: Invalid value",
+			},
+		},
+		{
+			name: "service name changed",
+			old:  testStatefulSet(),
+			new: func() *appsv1.StatefulSet {
+				sts := testStatefulSet()
+				sts.Spec.ServiceName = "other"
+				return sts
+			}(),
+			errors: []string{"spec.serviceName: Invalid value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := &ErrorList{}
+			CheckUpdateRules(tt.old, tt.new, rules...)(list)
+			var errors []string
+			for _, err := range list.Errors() {
+				errors = append(errors, err.Field+": "+err.Type.String())
+			}
+			if !reflect.DeepEqual(errors, tt.errors) {
+				t.Errorf("expected the errors %v, got: %v", tt.errors, list.Errors())
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}
+	old := testStatefulSet(testClaim{"fast", "2Gi"})
+	err := ValidateUpdate(gvk, old, testStatefulSet(testClaim{"fast", "1Gi"}),
+		[]UpdateRule{IncreaseOnly("spec.volumeClaimTemplates[*].spec.resources.requests.storage")},
+		func(list *ErrorList) {
+			list.Required(field.NewPath("spec", "selector"), "")
+		})
+	if err == nil {
+		t.Fatal("expected an update error")
+	}
+	for _, want := range []string{"spec.volumeClaimTemplates[0].spec.resources.requests.storage", "spec.selector"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error of %s, got: %v", want, err)
+		}
+	}
+	warnings, err := ValidateUpdateWithWarnings(gvk, old, testStatefulSet(testClaim{"fast", "3Gi"}),
+		[]UpdateRule{IncreaseOnly("spec.volumeClaimTemplates[*].spec.resources.requests.storage")},
+		func(list *ErrorList) {
+			list.Warn(field.NewPath("spec", "replicas"), "not set")
+		})
+	if err != nil {
+		t.Fatalf("expected no update error, got: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected one warning, got: %v", warnings)
+	}
+}
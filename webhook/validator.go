@@ -17,32 +17,104 @@
 package webhook
 
 import (
+	"fmt"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // Validate is a helper function to validate a CR with a error
 // list object to aggregate the error and return the invalid error or nil depending
 // if the validator func added at least one error to the passed error list object
 func Validate(gvk schema.GroupVersionKind, name string, validatorFuncs ...func(list *ErrorList)) error {
-	errList := &ErrorList{list: make([]*field.Error, 0)}
+	_, err := ValidateWithWarnings(gvk, name, validatorFuncs...)
+	return err
+}
+
+// ValidateWithWarnings is Validate also returning the warnings added to the error list,
+// which are sent back to the client e.g. kubectl whether the CR is valid or not
+func ValidateWithWarnings(gvk schema.GroupVersionKind, name string, validatorFuncs ...func(list *ErrorList)) (admission.Warnings, error) {
+	errList := &ErrorList{list: make(field.ErrorList, 0)}
 	for _, Func := range validatorFuncs {
 		Func(errList)
 	}
 	if len(errList.list) == 0 {
-		return nil
+		return errList.warnings, nil
 	}
-	return errors.NewInvalid(gvk.GroupKind(), name, errList.list)
+	return errList.warnings, errors.NewInvalid(gvk.GroupKind(), name, errList.list)
+}
+
+// Path creates the path of a field e.g. Path("spec", "replicas"), to pass to the ErrorList helpers
+func Path(name string, moreNames ...string) *field.Path {
+	return field.NewPath(name, moreNames...)
 }
 
 // ErrorList is a wrapper of field.Error
 // to enable convenient error adding
 type ErrorList struct {
-	list []*field.Error
+	list     field.ErrorList
+	warnings admission.Warnings
 }
 
 // Add adds the specified error the error list array
 func (e *ErrorList) Add(err *field.Error) {
 	e.list = append(e.list, err)
 }
+
+// AddAll adds the errors e.g. returned by the Validate method of a sub struct
+func (e *ErrorList) AddAll(errs field.ErrorList) {
+	e.list = append(e.list, errs...)
+}
+
+// Merge adds the errors and warnings of the other list
+func (e *ErrorList) Merge(other *ErrorList) {
+	e.list = append(e.list, other.list...)
+	e.warnings = append(e.warnings, other.warnings...)
+}
+
+// Required adds an error of a missing required field
+func (e *ErrorList) Required(path *field.Path, detail string) {
+	e.Add(field.Required(path, detail))
+}
+
+// Invalid adds an error of a field with an invalid value
+func (e *ErrorList) Invalid(path *field.Path, value interface{}, detail string) {
+	e.Add(field.Invalid(path, value, detail))
+}
+
+// Forbidden adds an error of a field which can not be set
+func (e *ErrorList) Forbidden(path *field.Path, detail string) {
+	e.Add(field.Forbidden(path, detail))
+}
+
+// NotSupported adds an error of a field whose value is not one of the valid values
+func (e *ErrorList) NotSupported(path *field.Path, value interface{}, validValues []string) {
+	e.Add(field.NotSupported(path, value, validValues))
+}
+
+// TooMany adds an error of a list field with more than the max items
+func (e *ErrorList) TooMany(path *field.Path, actual, maxItems int) {
+	e.Add(field.TooMany(path, actual, maxItems))
+}
+
+// Warn adds a warning of the field e.g. a deprecated field or a risky value. It does not
+// fail the validation but is shown to the client e.g. kubectl
+func (e *ErrorList) Warn(path *field.Path, format string, args ...interface{}) {
+	e.warnings = append(e.warnings, fmt.Sprintf("%s: %s", path.String(), fmt.Sprintf(format, args...)))
+}
+
+// Errors returns the added errors
+func (e *ErrorList) Errors() field.ErrorList {
+	return e.list
+}
+
+// Warnings returns the added warnings
+func (e *ErrorList) Warnings() admission.Warnings {
+	return e.warnings
+}
+
+// HasErrors checks if any error is added
+func (e *ErrorList) HasErrors() bool {
+	return len(e.list) > 0
+}
@@ -17,23 +17,57 @@
 package webhook
 
 import (
+	"context"
+	"fmt"
 	"github.com/monimesl/operator-helper/config"
 	"github.com/monimesl/operator-helper/reconciler"
 	"k8s.io/apimachinery/pkg/runtime"
 	"log"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// Context returns the Context of the deprecated reconciler.GetContext
+// Deprecated. New code should implement ContextValidator and use ConfigureWithContext
 func Context() reconciler.Context {
 	return reconciler.GetContext()
 }
 
+// ContextValidator is implemented by the CR types whose validation needs the
+// reconciler Context, e.g. to look up other objects. See ConfigureWithContext
+type ContextValidator interface {
+	runtime.Object
+
+	// ValidateCreateWithContext validates the object on creation
+	ValidateCreateWithContext(ctx reconciler.Context) (admission.Warnings, error)
+
+	// ValidateUpdateWithContext validates the object on update
+	ValidateUpdateWithContext(ctx reconciler.Context, old runtime.Object) (admission.Warnings, error)
+
+	// ValidateDeleteWithContext validates the object on deletion
+	ValidateDeleteWithContext(ctx reconciler.Context) (admission.Warnings, error)
+}
+
 // Configure configures the webhook for the added CR types
 func Configure(manager ctrl.Manager, apiTypes ...runtime.Object) error {
+	return ConfigureWithContext(reconciler.NewContext(manager), apiTypes...)
+}
+
+// ConfigureWithContext configures the webhook for the added CR types with the Context.
+// The CR types implementing ContextValidator are validated with the Context passed in and
+// those implementing reconciler.Defaulting, but not admission.Defaulter, are defaulted on admission
+func ConfigureWithContext(ctx reconciler.Context, apiTypes ...runtime.Object) error {
 	if config.WebHooksEnabled() {
 		for _, apiType := range apiTypes {
 			log.Printf("configuring the webhook: %T\n", apiType)
-			if err := ctrl.NewWebhookManagedBy(manager).For(apiType).Complete(); err != nil {
+			bldr := ctx.NewWebhookBuilder().For(apiType)
+			if _, ok := apiType.(ContextValidator); ok {
+				bldr = bldr.WithValidator(&contextValidator{ctx: ctx})
+			}
+			if defaultingType(apiType) {
+				bldr = bldr.WithDefaulter(&defaulter{})
+			}
+			if err := bldr.Complete(); err != nil {
 				return err
 			}
 		}
@@ -42,3 +76,45 @@ func Configure(manager ctrl.Manager, apiTypes ...runtime.Object) error {
 	}
 	return nil
 }
+
+// contextValidator adapts a ContextValidator to admission.CustomValidator
+type contextValidator struct {
+	ctx reconciler.Context
+}
+
+func (v *contextValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return obj.(ContextValidator).ValidateCreateWithContext(v.ctx)
+}
+
+func (v *contextValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return newObj.(ContextValidator).ValidateUpdateWithContext(v.ctx, oldObj)
+}
+
+func (v *contextValidator) ValidateDelete(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return obj.(ContextValidator).ValidateDeleteWithContext(v.ctx)
+}
+
+// defaultingType checks if the type should be defaulted by the defaulter. The types implementing
+// admission.Defaulter are defaulted by their own Default method instead
+func defaultingType(apiType runtime.Object) bool {
+	if _, ok := apiType.(admission.Defaulter); ok {
+		return false
+	}
+	_, ok := apiType.(reconciler.Defaulting)
+	return ok
+}
+
+// defaulter adapts a reconciler.Defaulting to admission.CustomDefaulter so the defaults
+// the reconcile loop sets are already set when the object is admitted
+type defaulter struct{}
+
+func (d *defaulter) Default(_ context.Context, obj runtime.Object) error {
+	defaulting, ok := obj.(reconciler.Defaulting)
+	if !ok {
+		return fmt.Errorf("expected a reconciler.Defaulting but got %T", obj)
+	}
+	defaulting.SetSpecDefaults()
+	// dropped by the API server when the status is a subresource
+	defaulting.SetStatusDefaults()
+	return nil
+}